@@ -0,0 +1,442 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/tcheck/health"
+	"github.com/uber/tcheck/internal/idl"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+// assertion is an expected value for a field of the result of a call.
+type assertion struct {
+	path     string
+	expected interface{}
+}
+
+func (a assertion) String() string {
+	b, _ := json.Marshal(a.expected)
+	return fmt.Sprintf("%v=%s", a.path, b)
+}
+
+// assertions is a flag.Value for --assert, which may be repeated.
+type assertions []assertion
+
+func (a *assertions) String() string {
+	s := make([]string, len(*a))
+	for i, as := range *a {
+		s[i] = as.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// Set parses path=value, where value is JSON, or a string if it isn't
+// valid JSON.
+func (a *assertions) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("assertion %q must be of the form path=value", v)
+	}
+
+	expected, err := decodeJSONValue(parts[1])
+	if err != nil {
+		expected = parts[1]
+	}
+	*a = append(*a, assertion{path: parts[0], expected: expected})
+	return nil
+}
+
+// assertionResult is the outcome of checking an assertion against a result.
+type assertionResult struct {
+	assertion
+	actual interface{}
+	found  bool
+}
+
+func (r assertionResult) passed() bool {
+	return r.found && jsonEqual(r.expected, r.actual)
+}
+
+func (r assertionResult) String() string {
+	if r.passed() {
+		return fmt.Sprintf("PASS %v", r.assertion)
+	}
+	if !r.found {
+		return fmt.Sprintf("FAIL %v, not found", r.assertion)
+	}
+	b, _ := json.Marshal(r.actual)
+	return fmt.Sprintf("FAIL %v, got %s", r.assertion, b)
+}
+
+// check looks up the assertion's path in the result, which must have been
+// normalized by decodeJSONValue.
+func (a assertion) check(result interface{}) assertionResult {
+	r := assertionResult{assertion: a}
+	r.actual, r.found = lookupPath(result, a.path)
+	return r
+}
+
+// lookupPath returns the value at a path of object keys and array indexes
+// separated by dots, where the path "." is the value itself.
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	if path == "." {
+		return v, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch container := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = container[part]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(container) {
+				return nil, false
+			}
+			v = container[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// decodeJSONValue decodes s, keeping numbers as json.Number so that large
+// integers are compared exactly.
+func decodeJSONValue(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the value")
+	}
+	return v, nil
+}
+
+// jsonEqual compares values decoded by decodeJSONValue, treating numbers as
+// equal if they have the same value, e.g. 1 and 1.0.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if ai, err := a.Int64(); err == nil {
+			if bi, err := b.Int64(); err == nil {
+				return ai == bi
+			}
+		}
+		af, aErr := a.Float64()
+		bf, bErr := b.Float64()
+		return aErr == nil && bErr == nil && af == bf
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if bv, ok := b[k]; !ok || !jsonEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// callResult is the result of calling a method with the call command.
+type callResult struct {
+	peer         string
+	resolvedPeer string
+	relay        string
+	serviceName  string
+	method       string
+	latency      time.Duration
+
+	// result is the decoded result, which is nil if the call failed.
+	result *idl.Result
+
+	assertions []assertionResult
+}
+
+func runCall(p printer, opts checkOptions) {
+	if len(peers) > 1 {
+		err := exitError{_exitUsage, "Must specify a single peer to call"}
+		p.printCall(callResult{serviceName: opts.serviceName, method: *method}, err)
+		exit(err)
+		return
+	}
+
+	var peer string
+	if len(peers) == 1 {
+		peer = peers[0]
+	}
+
+	result, err := call(peer, *hostsFile, *idlFile, *method, *callArgs, asserts, opts)
+	p.printCall(result, err)
+	exit(err)
+}
+
+// call calls the method with the JSON args on the service at peer, or through
+// the Hyperbahn relays listed in hostsFile if peer is empty. The IDL used to
+// encode the call is read from idlFile, or fetched using Meta::thriftIDL if
+// idlFile is empty.
+func call(peer, hostsFile, idlFile, method, args string, asserts []assertion, opts checkOptions) (callResult, error) {
+	result := callResult{peer: peer, serviceName: opts.serviceName, method: method}
+	if method == "" {
+		return result, exitError{_exitUsage, "Must specify a method to call"}
+	}
+	if peer == "" && hostsFile == "" {
+		return result, exitError{_exitUsage, "Must specify a peer or a Hyperbahn hosts file to call"}
+	}
+	if err := validateArgs(opts); err != nil {
+		return result, err
+	}
+
+	var prog *idl.Program
+	if idlFile != "" {
+		var err error
+		if prog, err = idl.ParseFile(idlFile); err != nil {
+			return result, exitError{_exitUsage, fmt.Sprintf("Failed to parse IDL file %v: %v", idlFile, err)}
+		}
+	}
+
+	var (
+		ch  *tchannel.Channel
+		err error
+	)
+	if peer != "" {
+		ch, err = newChannel(opts)
+	} else {
+		ch, err = newHyperbahnChannel(opts, hostsFile)
+	}
+	if err != nil {
+		return result, err
+	}
+	defer ch.Close()
+
+	var clientOpts *thrift.ClientOptions
+	if peer != "" {
+		result.resolvedPeer = health.RemapLocalhost(peer)
+		clientOpts = &thrift.ClientOptions{HostPort: result.resolvedPeer}
+	}
+	client := thrift.NewClient(ch, opts.serviceName, clientOpts)
+
+	err = invoke(client, prog, args, asserts, opts, &result)
+	if peer == "" {
+		result.relay = health.ConnectedPeer(ch)
+	}
+	return result, err
+}
+
+// invoke calls the method in r using client, fetching the IDL if prog is nil,
+// and records the outcome in r.
+func invoke(client thrift.TChanClient, prog *idl.Program, args string, asserts []assertion, opts checkOptions, r *callResult) error {
+	if prog == nil {
+		idls, err := callThriftIDL(client, opts)
+		if err != nil {
+			return exitError{errExitCode(err), fmt.Sprintf("Failed to get IDL for %v\nError: %v\n", opts.serviceName, err)}
+		}
+		if prog, err = parseThriftIDLs(idls); err != nil {
+			return exitError{_exitUnknownUnhealthy, fmt.Sprintf("Failed to parse IDL for %v: %v", opts.serviceName, err)}
+		}
+	}
+
+	service, f, err := prog.Method(r.method)
+	if err != nil {
+		return exitError{_exitUsage, fmt.Sprintf("Invalid method: %v", err)}
+	}
+	thriftArgs, err := prog.NewArgs(f, []byte(args))
+	if err != nil {
+		return exitError{_exitUsage, fmt.Sprintf("Invalid args for %v: %v", r.method, err)}
+	}
+
+	ctx, cancel := newContext(opts)
+	defer cancel()
+
+	res := prog.NewResult(f)
+	started := time.Now()
+	success, err := client.Call(ctx, service, f.Name, thriftArgs, res)
+	r.latency = time.Since(started)
+	if err != nil {
+		return exitError{errExitCode(err), fmt.Sprintf("Failed to call %v\nError: %v\n", r.method, err)}
+	}
+	r.result = res
+
+	switch {
+	case res.Exception != nil:
+		return exitError{_exitCallException, fmt.Sprintf("%v threw %v", r.method, res.Exception)}
+	case !success:
+		return exitError{_exitUnknownUnhealthy, fmt.Sprintf("%v returned no result or an unknown exception", r.method)}
+	case f.Returns != nil && res.Success == nil:
+		return exitError{_exitUnknownUnhealthy, fmt.Sprintf("%v returned no result", r.method)}
+	}
+	return checkAssertions(r, asserts)
+}
+
+// checkAssertions checks each assertion against the result of the call, and
+// returns an error if any failed.
+func checkAssertions(r *callResult, asserts []assertion) error {
+	if len(asserts) == 0 {
+		return nil
+	}
+
+	// Round trip the result through JSON so it's compared as it's printed,
+	// e.g. with binary values as base64.
+	b, err := json.Marshal(r.result.Success)
+	if err != nil {
+		return exitError{_exitUnknown, fmt.Sprintf("Failed to encode result: %v", err)}
+	}
+	v, err := decodeJSONValue(string(b))
+	if err != nil {
+		return exitError{_exitUnknown, fmt.Sprintf("Failed to decode result: %v", err)}
+	}
+
+	var failed int
+	for _, a := range asserts {
+		ar := a.check(v)
+		if !ar.passed() {
+			failed++
+		}
+		r.assertions = append(r.assertions, ar)
+	}
+	if failed > 0 {
+		return exitError{_exitAssertionFailed, fmt.Sprintf("%v of %v assertions failed", failed, len(asserts))}
+	}
+	return nil
+}
+
+// jsonCallResult is the record written for the call command with --output json.
+type jsonCallResult struct {
+	Peer         string          `json:"peer,omitempty"`
+	ResolvedPeer string          `json:"resolvedPeer,omitempty"`
+	Relay        string          `json:"relay,omitempty"`
+	Service      string          `json:"service"`
+	Method       string          `json:"method"`
+	OK           bool            `json:"ok"`
+	Result       interface{}     `json:"result,omitempty"`
+	Exception    *jsonException  `json:"exception,omitempty"`
+	Assertions   []jsonAssertion `json:"assertions,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	ErrorClass   string          `json:"errorClass,omitempty"`
+	ExitCode     int             `json:"exitCode"`
+	LatencyMs    float64         `json:"latencyMs"`
+}
+
+// jsonException is a declared exception thrown by the method.
+type jsonException struct {
+	Field string      `json:"field"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// jsonAssertion is the outcome of an assertion on the result.
+type jsonAssertion struct {
+	Path     string      `json:"path"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual,omitempty"`
+	Passed   bool        `json:"passed"`
+}
+
+func newJSONCallResult(r callResult, err error) jsonCallResult {
+	jr := jsonCallResult{
+		Peer:         r.peer,
+		ResolvedPeer: r.resolvedPeer,
+		Relay:        r.relay,
+		Service:      r.serviceName,
+		Method:       r.method,
+		OK:           err == nil,
+		LatencyMs:    r.latency.Seconds() * 1000,
+	}
+	if res := r.result; res != nil {
+		jr.Result = res.Success
+		if e := res.Exception; e != nil {
+			jr.Exception = &jsonException{Field: e.Field, Type: e.Type, Value: e.Value}
+		}
+	}
+	for _, a := range r.assertions {
+		jr.Assertions = append(jr.Assertions, jsonAssertion{
+			Path:     a.path,
+			Expected: a.expected,
+			Actual:   a.actual,
+			Passed:   a.passed(),
+		})
+	}
+	if err != nil {
+		jr.Error = err.Error()
+		jr.ExitCode = getExitCode(err)
+		jr.ErrorClass = errorClass(jr.ExitCode)
+	}
+	return jr
+}
+
+// printCall prints the decoded result of the call as indented JSON, followed
+// by the outcome of each assertion.
+func (p printer) printCall(r callResult, err error) {
+	if p.format == outputJSON {
+		p.writeJSON(newJSONCallResult(r, err))
+		return
+	}
+
+	if r.relay != "" {
+		fmt.Fprintln(p.w, "Relay:", r.relay)
+	}
+	if res := r.result; res != nil && res.Exception == nil {
+		b, jsonErr := json.MarshalIndent(res.Success, "", "  ")
+		if jsonErr != nil {
+			fmt.Fprintln(p.w, "Failed to encode result:", jsonErr)
+		} else {
+			fmt.Fprintf(p.w, "%s\n", b)
+		}
+	}
+	for _, a := range r.assertions {
+		fmt.Fprintln(p.w, a)
+	}
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+	}
+}
@@ -0,0 +1,415 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/tchecktest"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+const callIDL = `
+exception NotFound {
+  1: string key
+}
+
+service KeyValue {
+  string get(1: string key) throws (1: NotFound notFound)
+}
+`
+
+type callIDLHandler struct {
+	meta.TChanMeta
+}
+
+func (callIDLHandler) ThriftIDL(_ thrift.Context) (string, error) {
+	return callIDL, nil
+}
+
+// kvServer serves KeyValue::get from callIDL using a fixed set of values.
+type kvServer map[string]string
+
+func (kvServer) Service() string { return "KeyValue" }
+
+func (kvServer) Methods() []string { return []string{"get"} }
+
+func (s kvServer) Handle(_ thrift.Context, _ string, protocol athrift.TProtocol) (bool, athrift.TStruct, error) {
+	key, err := readKey(protocol)
+	if err != nil {
+		return false, nil, err
+	}
+	if v, ok := s[key]; ok {
+		return true, &kvResult{value: &v}, nil
+	}
+	return false, &kvResult{notFound: &key}, nil
+}
+
+// readKey reads the key from KeyValue::get's args.
+func readKey(iprot athrift.TProtocol) (string, error) {
+	var key string
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return "", err
+	}
+	for {
+		_, fieldType, fieldID, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return "", err
+		}
+		if fieldType == athrift.STOP {
+			break
+		}
+		if fieldID == 1 && fieldType == athrift.STRING {
+			key, err = iprot.ReadString()
+		} else {
+			err = iprot.Skip(fieldType)
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return "", err
+		}
+	}
+	return key, iprot.ReadStructEnd()
+}
+
+// kvResult is the result of KeyValue::get, which is either a value or the
+// NotFound exception with the key.
+type kvResult struct {
+	value    *string
+	notFound *string
+}
+
+func (r *kvResult) Read(iprot athrift.TProtocol) error {
+	return iprot.Skip(athrift.STRUCT)
+}
+
+func (r *kvResult) Write(oprot athrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("get_result"); err != nil {
+		return err
+	}
+	if r.value != nil {
+		if err := oprot.WriteFieldBegin("success", athrift.STRING, 0); err != nil {
+			return err
+		}
+		if err := oprot.WriteString(*r.value); err != nil {
+			return err
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if r.notFound != nil {
+		if err := oprot.WriteFieldBegin("notFound", athrift.STRUCT, 1); err != nil {
+			return err
+		}
+		if err := oprot.WriteStructBegin("NotFound"); err != nil {
+			return err
+		}
+		if err := oprot.WriteFieldBegin("key", athrift.STRING, 1); err != nil {
+			return err
+		}
+		if err := oprot.WriteString(*r.notFound); err != nil {
+			return err
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return err
+		}
+		if err := oprot.WriteFieldStop(); err != nil {
+			return err
+		}
+		if err := oprot.WriteStructEnd(); err != nil {
+			return err
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return err
+	}
+	return oprot.WriteStructEnd()
+}
+
+// setupCallServer returns a server for KeyValue that serves callIDL.
+func setupCallServer(t *testing.T) *tchannel.Channel {
+	ch := setupServer(t, nil)
+	server := thrift.NewServer(ch)
+	server.Register(meta.NewTChanMetaServer(callIDLHandler{}))
+	server.Register(kvServer{"a": "A"})
+	return ch
+}
+
+func TestAssertions(t *testing.T) {
+	var a assertions
+	require.NoError(t, a.Set("ok=true"), "Set failed")
+	require.NoError(t, a.Set("message=healthy"), "Set failed")
+	require.NoError(t, a.Set(`items.1.name="x=y"`), "Set failed")
+	assert.Equal(t, assertions{
+		{path: "ok", expected: true},
+		{path: "message", expected: "healthy"},
+		{path: "items.1.name", expected: "x=y"},
+	}, a, "Values that aren't JSON should be strings")
+	assert.Equal(t, `ok=true,message="healthy",items.1.name="x=y"`, a.String())
+
+	assert.Error(t, a.Set("ok"), "Expected missing value to fail")
+	assert.Error(t, a.Set("=true"), "Expected missing path to fail")
+}
+
+func TestAssertionCheck(t *testing.T) {
+	result, err := decodeJSONValue(`{"ok": true, "count": 2, "items": [{"name": "x"}], "big": 9007199254740993}`)
+	require.NoError(t, err, "Failed to decode result")
+
+	tests := []struct {
+		assert string
+		want   string
+	}{
+		{"ok=true", "PASS ok=true"},
+		{"ok=false", "FAIL ok=false, got true"},
+		{"count=2.0", "PASS count=2.0"},
+		{"big=9007199254740992", "FAIL big=9007199254740992, got 9007199254740993"},
+		{"items.0.name=x", `PASS items.0.name="x"`},
+		{"items.1.name=x", `FAIL items.1.name="x", not found`},
+		{"items.name=x", `FAIL items.name="x", not found`},
+		{`items=[{"name": "x"}]`, `PASS items=[{"name":"x"}]`},
+		{".={}", "FAIL .={}, got {\"big\":9007199254740993,\"count\":2,\"items\":[{\"name\":\"x\"}],\"ok\":true}"},
+	}
+
+	for _, tt := range tests {
+		var a assertions
+		require.NoError(t, a.Set(tt.assert), "Set failed for %v", tt.assert)
+		assert.Equal(t, tt.want, a[0].check(result).String(), "Unexpected result for %v", tt.assert)
+	}
+}
+
+func TestCall(t *testing.T) {
+	server := setupCallServer(t)
+	defer server.Close()
+
+	hostPort := server.PeerInfo().HostPort
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+
+	tests := []struct {
+		msg      string
+		method   string
+		args     string
+		asserts  []string
+		want     interface{}
+		wantExit int
+		wantErr  string
+	}{
+		{
+			msg:    "value",
+			method: "KeyValue::get",
+			args:   `{"key": "a"}`,
+			want:   "A",
+		},
+		{
+			msg:     "passing assertion",
+			method:  "KeyValue::get",
+			args:    `{"key": "a"}`,
+			asserts: []string{".=A"},
+			want:    "A",
+		},
+		{
+			msg:      "failing assertion",
+			method:   "KeyValue::get",
+			args:     `{"key": "a"}`,
+			asserts:  []string{".=A", ".=B"},
+			want:     "A",
+			wantExit: _exitAssertionFailed,
+			wantErr:  "1 of 2 assertions failed",
+		},
+		{
+			msg:      "exception",
+			method:   "KeyValue::get",
+			args:     `{"key": "b"}`,
+			wantExit: _exitCallException,
+			wantErr:  `KeyValue::get threw NotFound (notFound): {"key":"b"}`,
+		},
+		{
+			msg:      "unknown method",
+			method:   "KeyValue::put",
+			wantExit: _exitUsage,
+			wantErr:  `unknown function "put"`,
+		},
+		{
+			msg:      "invalid args",
+			method:   "KeyValue::get",
+			args:     `{"key": 1}`,
+			wantExit: _exitUsage,
+			wantErr:  "args.key: expected string, got a number",
+		},
+		{
+			msg:      "no method",
+			wantExit: _exitUsage,
+			wantErr:  "Must specify a method to call",
+		},
+	}
+
+	for _, tt := range tests {
+		var asserts assertions
+		for _, a := range tt.asserts {
+			require.NoError(t, asserts.Set(a), "%v: invalid assertion", tt.msg)
+		}
+
+		result, err := call(hostPort, "", "", tt.method, tt.args, asserts, opts)
+		if tt.wantExit != 0 {
+			if assert.Error(t, err, "%v: expected error", tt.msg) {
+				assert.Equal(t, tt.wantExit, getExitCode(err), "%v: unexpected exit code", tt.msg)
+				assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			}
+		} else {
+			assert.NoError(t, err, "%v: call failed", tt.msg)
+		}
+		if tt.want != nil && assert.NotNil(t, result.result, "%v: missing result", tt.msg) {
+			assert.Equal(t, tt.want, result.result.Success, "%v: unexpected result", tt.msg)
+		}
+		assert.Len(t, result.assertions, len(tt.asserts), "%v: unexpected assertions", tt.msg)
+	}
+
+	_, err := call("", "", "", "KeyValue::get", "{}", nil, opts)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing peer to fail")
+}
+
+func TestCallIDLFile(t *testing.T) {
+	server := tchecktest.NewServer(t, "svc")
+	defer server.Close()
+	server.SetResponse(tchecktest.Unhealthy("draining"))
+
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+	result, err := call(server.HostPort(), "", "meta.thrift", "Meta::health", `{"hr": {"type": "TRAFFIC"}}`, nil, opts)
+	require.NoError(t, err, "Call using a local IDL failed")
+	assert.Equal(t, map[string]interface{}{"ok": false, "message": "draining"}, result.result.Success)
+
+	// The test server has no IDL set, so the IDL can't be fetched.
+	_, err = call(server.HostPort(), "", "", "Meta::health", "{}", nil, opts)
+	assert.Equal(t, _exitUnknownUnhealthy, getExitCode(err), "Expected missing IDL to fail")
+
+	_, err = call(server.HostPort(), "", "missing.thrift", "Meta::health", "{}", nil, opts)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing IDL file to fail")
+}
+
+func TestCallHyperbahn(t *testing.T) {
+	relay := setupCallServer(t)
+	defer relay.Close()
+
+	hostsFile := writeHostsFile(t, relay.PeerInfo().HostPort)
+	defer os.Remove(hostsFile)
+
+	result, err := call("", hostsFile, "", "KeyValue::get", `{"key": "a"}`, nil, checkOptions{serviceName: "svc", timeout: time.Second})
+	require.NoError(t, err, "Failed to call through Hyperbahn")
+	assert.Equal(t, relay.PeerInfo().HostPort, result.relay, "Unexpected relay")
+}
+
+func TestPrintCall(t *testing.T) {
+	server := setupCallServer(t)
+	defer server.Close()
+
+	var asserts assertions
+	require.NoError(t, asserts.Set(".=B"), "Set failed")
+
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+	result, err := call(server.PeerInfo().HostPort, "", "", "KeyValue::get", `{"key": "a"}`, asserts, opts)
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputText).printCall(result, err)
+	assert.Equal(t, "\"A\"\nFAIL .=\"B\", got \"A\"\n1 of 1 assertions failed\n", buf.String())
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printCall(result, err)
+	var jr jsonCallResult
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &jr), "Failed to decode JSON result")
+	assert.Equal(t, "KeyValue::get", jr.Method, "Unexpected method")
+	assert.Equal(t, "A", jr.Result, "Unexpected result")
+	assert.Equal(t, "assertion", jr.ErrorClass, "Unexpected error class")
+	assert.Equal(t, []jsonAssertion{{Path: ".", Expected: "B", Actual: "A"}}, jr.Assertions)
+
+	result, err = call(server.PeerInfo().HostPort, "", "", "KeyValue::get", `{"key": "b"}`, nil, opts)
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printCall(result, err)
+	jr = jsonCallResult{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &jr), "Failed to decode JSON result")
+	assert.Nil(t, jr.Result, "Unexpected result")
+	assert.Equal(t, &jsonException{Field: "notFound", Type: "NotFound", Value: map[string]interface{}{"key": "b"}}, jr.Exception)
+	assert.Equal(t, "exception", jr.ErrorClass, "Unexpected error class")
+}
+
+func TestIntegrationCall(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	server := setupCallServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		args     []string
+		wantExit int
+	}{
+		{
+			args: []string{"--args", `{"key": "a"}`, "--assert", ".=A"},
+		},
+		{
+			args:     []string{"--args", `{"key": "a"}`, "--assert", ".=B"},
+			wantExit: _exitAssertionFailed,
+		},
+		{
+			args:     []string{"--args", `{"key": "b"}`},
+			wantExit: _exitCallException,
+		},
+		{
+			args:     []string{"--peer", "127.0.0.1:1"},
+			wantExit: _exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			args := append([]string{"call", "--peer", server.PeerInfo().HostPort, "--serviceName", "svc", "--method", "KeyValue::get"}, tt.args...)
+			setArgs(args...)
+			main()
+		}()
+
+		<-done
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for %v", tt.args)
+	}
+}
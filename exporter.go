@@ -0,0 +1,286 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+// _scrapeTimeoutOffset is subtracted from the scrape timeout sent by
+// Prometheus, so the probe finishes before Prometheus gives up on it.
+const _scrapeTimeoutOffset = 500 * time.Millisecond
+
+// exporter serves Prometheus metrics for health checks of TChannel services,
+// in the style of the blackbox exporter.
+type exporter struct {
+	ch      *tchannel.Channel
+	opts    checkOptions
+	started time.Time
+
+	sync.Mutex
+	probes       map[string]int
+	failures     map[string]int
+	inFlight     int
+	probeSeconds float64
+}
+
+func newExporter(opts checkOptions) (*exporter, error) {
+	ch, err := newChannel(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &exporter{
+		ch:       ch,
+		opts:     opts,
+		started:  time.Now(),
+		probes:   make(map[string]int),
+		failures: make(map[string]int),
+	}, nil
+}
+
+func (e *exporter) close() {
+	e.ch.Close()
+}
+
+func (e *exporter) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/probe", e.probe)
+	mux.HandleFunc("/metrics", e.metrics)
+	return mux
+}
+
+// probe health checks the target and service in the request, and writes the
+// result as metrics.
+func (e *exporter) probe(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "Must specify a target host:port", http.StatusBadRequest)
+		return
+	}
+
+	opts := e.opts
+	if service := r.URL.Query().Get("service"); service != "" {
+		opts.serviceName = service
+	}
+	if opts.deadline == 0 {
+		opts.deadline = scrapeTimeout(r)
+	}
+
+	hc, err := newHealthChecker(e.ch, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e.Lock()
+	e.inFlight++
+	e.Unlock()
+
+	started := time.Now()
+	result := checkTarget(hc, target, opts)
+	exitErr := result.exitErr()
+
+	// Only peers that responded to the health check are asked for their
+	// version, so an unreachable peer doesn't take twice as long to probe,
+	// and only in the time left before the deadline, so a slow peer can't
+	// make the probe outlast the scrape.
+	var info *meta.VersionInfo
+	versionOpts := opts
+	if opts.deadline > 0 {
+		if left := opts.deadline - time.Since(started); left < versionOpts.timeout {
+			versionOpts.timeout = left
+		}
+	}
+	if result.err == nil && (opts.deadline == 0 || versionOpts.timeout > 0) {
+		client := thrift.NewClient(e.ch, opts.serviceName, &thrift.ClientOptions{HostPort: result.ResolvedPeer})
+		info = callVersionInfo(client, versionOpts).info
+	}
+
+	e.record(result, exitErr)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeProbeMetrics(w, result, exitErr, info)
+}
+
+// scrapeTimeout returns the time left for the probe based on the scrape
+// timeout sent by Prometheus, or 0 if it wasn't sent.
+func scrapeTimeout(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > _scrapeTimeoutOffset {
+		timeout -= _scrapeTimeoutOffset
+	}
+	return timeout
+}
+
+// record updates the exporter's own stats for a probe.
+func (e *exporter) record(r checkResult, err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.inFlight--
+	e.probeSeconds += r.Latency.Seconds()
+	if err == nil {
+		e.probes["success"]++
+		return
+	}
+	e.probes["failure"]++
+	e.failures[errorClass(getExitCode(err))]++
+}
+
+func writeProbeMetrics(w io.Writer, r checkResult, err error, info *meta.VersionInfo) {
+	writeMetric(w, "probe_success", "gauge", "Whether the health check succeeded",
+		metricSample{value: boolValue(err == nil)})
+	writeMetric(w, "probe_duration_seconds", "gauge", "Time taken by the health check, including retries",
+		metricSample{value: r.Latency.Seconds()})
+	writeMetric(w, "probe_tchannel_unhealthy", "gauge", "Whether the service responded and reported itself unhealthy",
+		metricSample{value: boolValue(r.err == nil && !r.Healthy)})
+	writeMetric(w, "probe_tchannel_attempts", "gauge", "Number of attempts made by the health check",
+		metricSample{value: float64(len(r.Attempts))})
+
+	var failed health.ErrorClass
+	if r.err != nil {
+		failed = health.Classify(r.err)
+	}
+	samples := make([]metricSample, len(health.ErrorClasses))
+	for i, class := range health.ErrorClasses {
+		samples[i] = metricSample{labels: formatLabels("class", string(class)), value: boolValue(class == failed)}
+	}
+	writeMetric(w, "probe_tchannel_error_class", "gauge", "Class of error if the health check call failed", samples...)
+
+	if info != nil {
+		labels := formatLabels("language", info.Language, "language_version", info.LanguageVersion, "version", info.Version)
+		writeMetric(w, "probe_tchannel_version_info", "gauge", "Language and TChannel library version reported by the service",
+			metricSample{labels: labels, value: 1})
+	}
+}
+
+// metrics writes the exporter's own stats.
+func (e *exporter) metrics(w http.ResponseWriter, r *http.Request) {
+	e.Lock()
+	defer e.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "tcheck_exporter_probes_total", "counter", "Number of probes by result",
+		metricSample{labels: formatLabels("result", "success"), value: float64(e.probes["success"])},
+		metricSample{labels: formatLabels("result", "failure"), value: float64(e.probes["failure"])})
+
+	classes := make([]string, 0, len(e.failures))
+	for class := range e.failures {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	samples := make([]metricSample, len(classes))
+	for i, class := range classes {
+		samples[i] = metricSample{labels: formatLabels("class", class), value: float64(e.failures[class])}
+	}
+	writeMetric(w, "tcheck_exporter_probe_failures_total", "counter", "Number of failed probes by error class", samples...)
+
+	writeMetric(w, "tcheck_exporter_probe_seconds_total", "counter", "Total time spent probing",
+		metricSample{value: e.probeSeconds})
+	writeMetric(w, "tcheck_exporter_probes_in_flight", "gauge", "Number of probes in progress",
+		metricSample{value: float64(e.inFlight)})
+	writeMetric(w, "tcheck_exporter_start_time_seconds", "gauge", "Time the exporter started, in seconds since the epoch",
+		metricSample{value: float64(e.started.Unix())})
+}
+
+// metricSample is a single sample of a metric, with labels formatted using
+// formatLabels.
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+// writeMetric writes a metric in the Prometheus text format.
+func writeMetric(w io.Writer, name, kind, help string, samples ...metricSample) {
+	fmt.Fprintf(w, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(w, "# TYPE %v %v\n", name, kind)
+	for _, s := range samples {
+		fmt.Fprintf(w, "%v%v %v\n", name, s.labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+var _labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats pairs of label names and values.
+func formatLabels(pairs ...string) string {
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf(`%v="%v"`, pairs[i], _labelEscaper.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func runExporter(p printer, opts checkOptions) {
+	e, ln, err := startExporter(*listen, opts)
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+		exit(err)
+		return
+	}
+	defer e.close()
+
+	fmt.Fprintf(p.w, "Serving metrics on http://%v/probe and http://%v/metrics\n", ln.Addr(), ln.Addr())
+	err = http.Serve(ln, e.handler())
+	exit(exitError{_exitUnknown, fmt.Sprintf("Failed to serve: %v", err)})
+}
+
+// startExporter returns an exporter and a listener on hostPort to serve it on.
+func startExporter(hostPort string, opts checkOptions) (*exporter, net.Listener, error) {
+	if hostPort == "" {
+		return nil, nil, exitError{_exitUsage, "Must specify a host:port to listen on"}
+	}
+
+	e, err := newExporter(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	ln, err := net.Listen("tcp", hostPort)
+	if err != nil {
+		e.close()
+		return nil, nil, exitError{_exitUnknown, fmt.Sprintf("Failed to listen on %v: %v", hostPort, err)}
+	}
+	return e, ln, nil
+}
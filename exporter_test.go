@@ -0,0 +1,254 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
+	"github.com/uber/tcheck/tchecktest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+func setupExporter(t *testing.T, opts checkOptions) (*httptest.Server, func()) {
+	e, err := newExporter(opts)
+	require.NoError(t, err, "Failed to create exporter")
+	server := httptest.NewServer(e.handler())
+	return server, func() {
+		server.Close()
+		e.close()
+	}
+}
+
+func httpGet(t *testing.T, u string, header http.Header) (int, string) {
+	req, err := http.NewRequest("GET", u, nil)
+	require.NoError(t, err, "Failed to create request")
+	req.Header = header
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "GET %v failed", u)
+	return resp.StatusCode, readBody(t, resp)
+}
+
+func probeURL(server *httptest.Server, target, service string) string {
+	q := url.Values{"target": {target}}
+	if service != "" {
+		q.Set("service", service)
+	}
+	return server.URL + "/probe?" + q.Encode()
+}
+
+func TestExporterProbe(t *testing.T) {
+	healthy := tchecktest.NewServer(t, "svc")
+	defer healthy.Close()
+
+	unhealthy := tchecktest.NewServer(t, "svc")
+	defer unhealthy.Close()
+	unhealthy.SetResponse(tchecktest.Unhealthy("draining"))
+
+	// Find a port that nothing is listening on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	ln.Close()
+
+	server, cleanup := setupExporter(t, checkOptions{timeout: time.Second})
+	defer cleanup()
+
+	tests := []struct {
+		msg      string
+		target   string
+		service  string
+		wantCode int
+		want     []string
+		notWant  []string
+	}{
+		{
+			msg:      "healthy",
+			target:   healthy.HostPort(),
+			service:  "svc",
+			wantCode: http.StatusOK,
+			want: []string{
+				"probe_success 1\n",
+				"probe_tchannel_unhealthy 0\n",
+				"probe_tchannel_attempts 1\n",
+				`probe_tchannel_error_class{class="timeout"} 0` + "\n",
+				`probe_tchannel_version_info{language="go",language_version=`,
+				`version="` + tchannel.VersionInfo + `"} 1`,
+			},
+		},
+		{
+			msg:      "unhealthy",
+			target:   unhealthy.HostPort(),
+			service:  "svc",
+			wantCode: http.StatusOK,
+			want: []string{
+				"probe_success 0\n",
+				"probe_tchannel_unhealthy 1\n",
+			},
+		},
+		{
+			msg:      "connection refused",
+			target:   ln.Addr().String(),
+			service:  "svc",
+			wantCode: http.StatusOK,
+			want: []string{
+				"probe_success 0\n",
+				"probe_tchannel_unhealthy 0\n",
+				`probe_tchannel_error_class{class="connection-refused"} 1` + "\n",
+			},
+			notWant: []string{"probe_tchannel_version_info"},
+		},
+		{
+			msg:      "missing target",
+			service:  "svc",
+			wantCode: http.StatusBadRequest,
+			want:     []string{"Must specify a target"},
+		},
+		{
+			msg:      "missing service",
+			target:   healthy.HostPort(),
+			wantCode: http.StatusBadRequest,
+			want:     []string{"Must specify a service name"},
+		},
+	}
+
+	for _, tt := range tests {
+		code, body := httpGet(t, probeURL(server, tt.target, tt.service), nil)
+		assert.Equal(t, tt.wantCode, code, "%v: unexpected status code", tt.msg)
+		for _, s := range tt.want {
+			assert.Contains(t, body, s, "%v: missing metric", tt.msg)
+		}
+		for _, s := range tt.notWant {
+			assert.NotContains(t, body, s, "%v: unexpected metric", tt.msg)
+		}
+	}
+
+	_, body := httpGet(t, server.URL+"/metrics", nil)
+	assert.Contains(t, body, `tcheck_exporter_probes_total{result="success"} 1`+"\n", "Unexpected successful probes")
+	assert.Contains(t, body, `tcheck_exporter_probes_total{result="failure"} 2`+"\n", "Unexpected failed probes")
+	assert.Contains(t, body, `tcheck_exporter_probe_failures_total{class="connection-refused"} 1`+"\n", "Missing failure class")
+	assert.Contains(t, body, `tcheck_exporter_probe_failures_total{class="unhealthy"} 1`+"\n", "Missing failure class")
+	assert.Contains(t, body, "tcheck_exporter_probes_in_flight 0\n", "Unexpected probes in flight")
+}
+
+func TestExporterDefaultService(t *testing.T) {
+	target := tchecktest.NewServer(t, "svc")
+	defer target.Close()
+
+	server, cleanup := setupExporter(t, checkOptions{serviceName: "svc", timeout: time.Second})
+	defer cleanup()
+
+	_, body := httpGet(t, probeURL(server, target.HostPort(), ""), nil)
+	assert.Contains(t, body, "probe_success 1\n", "Expected --serviceName to be used")
+}
+
+func TestExporterScrapeTimeout(t *testing.T) {
+	target := tchecktest.NewServer(t, "svc")
+	defer target.Close()
+	target.SetResponse(tchecktest.Response{Err: tchannel.ErrServerBusy})
+
+	opts := checkOptions{
+		timeout: time.Second,
+		retry:   health.RetryPolicy{Retries: 10, Backoff: 50 * time.Millisecond, On: []health.ErrorClass{health.ClassBusy}},
+	}
+	server, cleanup := setupExporter(t, opts)
+	defer cleanup()
+
+	header := http.Header{"X-Prometheus-Scrape-Timeout-Seconds": {"0.6"}}
+	started := time.Now()
+	_, body := httpGet(t, probeURL(server, target.HostPort(), "svc"), header)
+	assert.True(t, time.Since(started) < 500*time.Millisecond, "Retries should stop before the scrape timeout")
+	assert.Contains(t, body, `probe_tchannel_error_class{class="busy"} 1`, "Unexpected error class")
+}
+
+// slowVersionHandler is healthy, but slow to return its version.
+type slowVersionHandler struct {
+	meta.TChanMeta
+
+	latency time.Duration
+}
+
+func (h slowVersionHandler) Health(ctx thrift.Context, _ *meta.HealthRequest) (*meta.HealthStatus, error) {
+	return &meta.HealthStatus{Ok: true}, nil
+}
+
+func (h slowVersionHandler) VersionInfo(ctx thrift.Context) (*meta.VersionInfo, error) {
+	time.Sleep(h.latency)
+	return &meta.VersionInfo{Language: "go"}, nil
+}
+
+func TestExporterScrapeTimeoutVersionInfo(t *testing.T) {
+	target := setupServer(t, nil)
+	defer target.Close()
+	thrift.NewServer(target).Register(meta.NewTChanMetaServer(slowVersionHandler{latency: 2 * time.Second}))
+
+	server, cleanup := setupExporter(t, checkOptions{timeout: 5 * time.Second})
+	defer cleanup()
+
+	header := http.Header{"X-Prometheus-Scrape-Timeout-Seconds": {"0.8"}}
+	started := time.Now()
+	_, body := httpGet(t, probeURL(server, target.PeerInfo().HostPort, target.ServiceName()), header)
+	assert.True(t, time.Since(started) < 800*time.Millisecond, "Version info call should stop before the scrape timeout")
+	assert.Contains(t, body, "probe_success 1\n", "Expected health check to succeed")
+	assert.NotContains(t, body, "probe_tchannel_version_info", "Version info should time out")
+}
+
+func TestScrapeTimeout(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"abc", 0},
+		{"-1", 0},
+		{"10", 9500 * time.Millisecond},
+		{"0.2", 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		r, err := http.NewRequest("GET", "/probe", nil)
+		require.NoError(t, err, "Failed to create request")
+		r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+		assert.Equal(t, tt.want, scrapeTimeout(r), "Unexpected timeout for %q", tt.header)
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, `{a="1",b="x\"y\\z\n"}`, formatLabels("a", "1", "b", "x\"y\\z\n"))
+}
+
+func TestStartExporterBadArgs(t *testing.T) {
+	_, _, err := startExporter("", checkOptions{})
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing host:port to fail")
+
+	_, _, err = startExporter("not-a-host-port", checkOptions{})
+	assert.Equal(t, _exitUnknown, getExitCode(err), "Expected invalid host:port to fail")
+}
@@ -0,0 +1,302 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/tcheck/health"
+
+	"github.com/uber/tchannel-go"
+)
+
+// gatewayRoute is a peer and service to health check for a gateway request.
+type gatewayRoute struct {
+	Service string `json:"service"`
+	Peer    string `json:"peer"`
+}
+
+// gatewayConfig maps names to routes, so load balancers can health check
+// GET /health/{name} without knowing the service name or peer.
+type gatewayConfig struct {
+	Routes map[string]gatewayRoute `json:"routes"`
+}
+
+func loadGatewayConfig(filename string) (*gatewayConfig, error) {
+	config := &gatewayConfig{}
+	if filename == "" {
+		return config, nil
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %v", filename, err)
+	}
+	for name, route := range config.Routes {
+		if route.Service == "" || route.Peer == "" {
+			return nil, fmt.Errorf("route %q must specify a service and peer", name)
+		}
+	}
+	return config, nil
+}
+
+// _maxGatewayCheckers is the maximum number of services that the gateway
+// keeps a health.Checker for, since any client can pick the service name.
+const _maxGatewayCheckers = 1000
+
+// cachedCheck is the result of a health check shared by all requests for
+// the same route until it expires.
+type cachedCheck struct {
+	done    chan struct{}
+	result  checkResult
+	checked bool
+	expires time.Time
+}
+
+// gateway serves HTTP health checks for TChannel services, so that load
+// balancers that only support HTTP health checks can check them.
+type gateway struct {
+	ch         *tchannel.Channel
+	opts       checkOptions
+	ttl        time.Duration
+	routes     map[string]gatewayRoute
+	routesOnly bool
+	now        func() time.Time
+
+	sync.Mutex
+	checkers map[string]*health.Checker
+	cache    map[gatewayRoute]*cachedCheck
+}
+
+// newGateway returns a gateway that health checks the routes in config, and
+// any {service}/{hostport} unless routesOnly is set.
+func newGateway(opts checkOptions, ttl time.Duration, config *gatewayConfig, routesOnly bool) (*gateway, error) {
+	ch, err := newChannel(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &gateway{
+		ch:         ch,
+		opts:       opts,
+		ttl:        ttl,
+		routes:     config.Routes,
+		routesOnly: routesOnly,
+		now:        time.Now,
+		checkers:   make(map[string]*health.Checker),
+		cache:      make(map[gatewayRoute]*cachedCheck),
+	}, nil
+}
+
+func (g *gateway) close() {
+	g.ch.Close()
+}
+
+func (g *gateway) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health/", g.health)
+	return mux
+}
+
+// health handles GET /health/{name} for a configured route, or
+// GET /health/{service}/{hostport}. It responds 200 if the service is
+// healthy, and 503 otherwise, with the health check's message as the body.
+func (g *gateway) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	route, ok := g.route(strings.TrimPrefix(r.URL.Path, "/health/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	result, err := g.check(route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case result.err != nil:
+		w.Header().Set("X-Tcheck-Result", string(health.Classify(result.err)))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, result.err)
+	case !result.Healthy:
+		w.Header().Set("X-Tcheck-Result", "unhealthy")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, result.Message)
+	default:
+		w.Header().Set("X-Tcheck-Result", "healthy")
+		fmt.Fprintln(w, result.Message)
+	}
+}
+
+// route returns the route for a request path, which is either the name of
+// a configured route, or {service}/{hostport} unless routesOnly is set.
+func (g *gateway) route(path string) (gatewayRoute, bool) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) == 1 {
+		route, ok := g.routes[parts[0]]
+		return route, ok
+	}
+	if g.routesOnly || parts[0] == "" || parts[1] == "" {
+		return gatewayRoute{}, false
+	}
+	return gatewayRoute{Service: parts[0], Peer: parts[1]}, true
+}
+
+// check returns the result of health checking the route. Results are cached
+// for the TTL, and concurrent requests for a route share a single health
+// check, so load balancers can't overwhelm the backends.
+func (g *gateway) check(route gatewayRoute) (checkResult, error) {
+	g.Lock()
+	if c, ok := g.cache[route]; ok && (!c.checked || g.now().Before(c.expires)) {
+		g.Unlock()
+		<-c.done
+		return c.result, nil
+	}
+
+	hc, err := g.checker(route.Service)
+	if err != nil {
+		g.Unlock()
+		return checkResult{}, err
+	}
+
+	g.evictExpired()
+	c := &cachedCheck{done: make(chan struct{})}
+	g.cache[route] = c
+	g.Unlock()
+
+	c.result = checkTarget(hc, route.Peer, g.opts)
+
+	g.Lock()
+	c.checked = true
+	c.expires = g.now().Add(g.ttl)
+	g.Unlock()
+	close(c.done)
+	return c.result, nil
+}
+
+// checker returns the health.Checker for the service. It must be called
+// with the lock held.
+func (g *gateway) checker(service string) (*health.Checker, error) {
+	if hc, ok := g.checkers[service]; ok {
+		return hc, nil
+	}
+
+	// Checkers share the gateway's channel, so they can be dropped without
+	// closing them, even while a health check is using them.
+	if len(g.checkers) >= _maxGatewayCheckers {
+		for s := range g.checkers {
+			delete(g.checkers, s)
+			break
+		}
+	}
+
+	opts := g.opts
+	opts.serviceName = service
+	hc, err := newHealthChecker(g.ch, opts)
+	if err != nil {
+		return nil, err
+	}
+	g.checkers[service] = hc
+	return hc, nil
+}
+
+// evictExpired removes expired results from the cache. It must be called
+// with the lock held.
+func (g *gateway) evictExpired() {
+	now := g.now()
+	for route, c := range g.cache {
+		if c.checked && !now.Before(c.expires) {
+			delete(g.cache, route)
+		}
+	}
+}
+
+// warning returns a warning to print at startup if the gateway health checks
+// any {service}/{hostport}, so it can be used to reach arbitrary peers.
+func (g *gateway) warning() string {
+	if g.routesOnly {
+		return ""
+	}
+	return "Warning: the gateway health checks any /health/{service}/{hostport} it's sent, use --routesOnly to only check the --routes"
+}
+
+func runGateway(p printer, opts checkOptions) {
+	g, ln, err := startGateway(*listen, *routesFile, *routesOnly, *cacheTTL, opts)
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+		exit(err)
+		return
+	}
+	defer g.close()
+
+	if warning := g.warning(); warning != "" {
+		fmt.Fprintln(p.w, warning)
+	}
+	fmt.Fprintf(p.w, "Serving health checks on http://%v/health/\n", ln.Addr())
+	err = http.Serve(ln, g.handler())
+	exit(exitError{_exitUnknown, fmt.Sprintf("Failed to serve: %v", err)})
+}
+
+// startGateway returns a gateway and a listener on hostPort to serve it on.
+func startGateway(hostPort, configFile string, routesOnly bool, ttl time.Duration, opts checkOptions) (*gateway, net.Listener, error) {
+	if hostPort == "" {
+		return nil, nil, exitError{_exitUsage, "Must specify a host:port to listen on"}
+	}
+	if ttl < 0 {
+		return nil, nil, exitError{_exitUsage, "Must specify a non-negative cache TTL"}
+	}
+
+	config, err := loadGatewayConfig(configFile)
+	if err != nil {
+		return nil, nil, exitError{_exitUsage, fmt.Sprintf("Failed to load routes: %v", err)}
+	}
+	if routesOnly && len(config.Routes) == 0 {
+		return nil, nil, exitError{_exitUsage, "Must specify --routes with --routesOnly"}
+	}
+
+	g, err := newGateway(opts, ttl, config, routesOnly)
+	if err != nil {
+		return nil, nil, err
+	}
+	ln, err := net.Listen("tcp", hostPort)
+	if err != nil {
+		g.close()
+		return nil, nil, exitError{_exitUnknown, fmt.Sprintf("Failed to listen on %v: %v", hostPort, err)}
+	}
+	return g, ln, nil
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/tchecktest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func setupGateway(t *testing.T, ttl time.Duration, config *gatewayConfig) (*gateway, *httptest.Server, func()) {
+	return setupGatewayRoutes(t, ttl, config, false)
+}
+
+func setupGatewayRoutes(t *testing.T, ttl time.Duration, config *gatewayConfig, routesOnly bool) (*gateway, *httptest.Server, func()) {
+	g, err := newGateway(checkOptions{timeout: time.Second}, ttl, config, routesOnly)
+	require.NoError(t, err, "Failed to create gateway")
+	server := httptest.NewServer(g.handler())
+	return g, server, func() {
+		server.Close()
+		g.close()
+	}
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "Failed to read response")
+	return string(body)
+}
+
+func TestGatewayHealth(t *testing.T) {
+	healthy := tchecktest.NewServer(t, "svc")
+	defer healthy.Close()
+	healthy.SetResponse(tchecktest.Response{Healthy: true, Message: "up"})
+
+	unhealthy := tchecktest.NewServer(t, "svc")
+	defer unhealthy.Close()
+	unhealthy.SetResponse(tchecktest.Unhealthy("draining"))
+
+	busy := tchecktest.NewServer(t, "svc")
+	defer busy.Close()
+	busy.SetResponse(tchecktest.Response{Err: tchannel.ErrServerBusy})
+
+	config := &gatewayConfig{Routes: map[string]gatewayRoute{
+		"kv": {Service: "svc", Peer: unhealthy.HostPort()},
+	}}
+	_, server, cleanup := setupGateway(t, 0, config)
+	defer cleanup()
+
+	tests := []struct {
+		path       string
+		wantCode   int
+		wantResult string
+		wantBody   string
+	}{
+		{
+			path:       "/health/svc/" + healthy.HostPort(),
+			wantCode:   http.StatusOK,
+			wantResult: "healthy",
+			wantBody:   "up\n",
+		},
+		{
+			path:       "/health/svc/" + unhealthy.HostPort(),
+			wantCode:   http.StatusServiceUnavailable,
+			wantResult: "unhealthy",
+			wantBody:   "draining\n",
+		},
+		{
+			path:       "/health/svc/" + busy.HostPort(),
+			wantCode:   http.StatusServiceUnavailable,
+			wantResult: "busy",
+			wantBody:   tchannel.ErrServerBusy.Error() + "\n",
+		},
+		{
+			path:       "/health/kv",
+			wantCode:   http.StatusServiceUnavailable,
+			wantResult: "unhealthy",
+			wantBody:   "draining\n",
+		},
+		{
+			path:     "/health/unknown",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			path:     "/health/svc/",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		require.NoError(t, err, "GET %v failed", tt.path)
+		body := readBody(t, resp)
+
+		assert.Equal(t, tt.wantCode, resp.StatusCode, "%v: unexpected status code", tt.path)
+		if tt.wantResult != "" {
+			assert.Equal(t, tt.wantResult, resp.Header.Get("X-Tcheck-Result"), "%v: unexpected result", tt.path)
+			assert.Equal(t, tt.wantBody, body, "%v: unexpected body", tt.path)
+		}
+	}
+}
+
+func TestGatewayMethods(t *testing.T) {
+	target := tchecktest.NewServer(t, "svc")
+	defer target.Close()
+
+	_, server, cleanup := setupGateway(t, 0, &gatewayConfig{})
+	defer cleanup()
+
+	url := server.URL + "/health/svc/" + target.HostPort()
+	tests := []struct {
+		method   string
+		wantCode int
+	}{
+		{"GET", http.StatusOK},
+		{"HEAD", http.StatusOK},
+		{"POST", http.StatusMethodNotAllowed},
+		{"DELETE", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, url, nil)
+		require.NoError(t, err, "Failed to create %v request", tt.method)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err, "%v failed", tt.method)
+		readBody(t, resp)
+
+		assert.Equal(t, tt.wantCode, resp.StatusCode, "%v: unexpected status code", tt.method)
+		if tt.wantCode == http.StatusMethodNotAllowed {
+			assert.Equal(t, "GET, HEAD", resp.Header.Get("Allow"), "%v: unexpected Allow header", tt.method)
+		}
+	}
+}
+
+func TestGatewayRoutesOnly(t *testing.T) {
+	target := tchecktest.NewServer(t, "svc")
+	defer target.Close()
+
+	config := &gatewayConfig{Routes: map[string]gatewayRoute{
+		"kv": {Service: "svc", Peer: target.HostPort()},
+	}}
+	g, server, cleanup := setupGatewayRoutes(t, 0, config, true)
+	defer cleanup()
+	assert.Empty(t, g.warning(), "Gateway with only configured routes shouldn't warn")
+
+	resp, err := http.Get(server.URL + "/health/kv")
+	require.NoError(t, err, "GET failed")
+	readBody(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Configured routes should be checked")
+
+	resp, err = http.Get(server.URL + "/health/svc/" + target.HostPort())
+	require.NoError(t, err, "GET failed")
+	readBody(t, resp)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "Only configured routes should be checked")
+}
+
+func TestGatewayCheckerLimit(t *testing.T) {
+	g, _, cleanup := setupGateway(t, 0, &gatewayConfig{})
+	defer cleanup()
+
+	for i := 0; i < _maxGatewayCheckers+10; i++ {
+		g.Lock()
+		_, err := g.checker(fmt.Sprintf("svc-%v", i))
+		g.Unlock()
+		require.NoError(t, err, "checker failed")
+	}
+	assert.Len(t, g.checkers, _maxGatewayCheckers, "Checkers should be limited")
+}
+
+func TestGatewayCache(t *testing.T) {
+	target := tchecktest.NewServer(t, "svc")
+	defer target.Close()
+
+	g, _, cleanup := setupGateway(t, time.Minute, &gatewayConfig{})
+	defer cleanup()
+
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	route := gatewayRoute{Service: "svc", Peer: target.HostPort()}
+	for i := 0; i < 3; i++ {
+		result, err := g.check(route)
+		require.NoError(t, err, "check failed")
+		assert.True(t, result.Healthy, "Expected healthy result")
+	}
+	assert.Equal(t, 1, target.Calls(), "Results should be cached")
+
+	target.SetResponse(tchecktest.Unhealthy("draining"))
+	now = now.Add(time.Minute)
+	result, err := g.check(route)
+	require.NoError(t, err, "check failed")
+	assert.False(t, result.Healthy, "Expected expired result to be checked again")
+	assert.Equal(t, 2, target.Calls(), "Unexpected calls")
+}
+
+func TestGatewaySharesConcurrentChecks(t *testing.T) {
+	target := tchecktest.NewServer(t, "svc")
+	defer target.Close()
+	target.SetLatency(50 * time.Millisecond)
+
+	g, _, cleanup := setupGateway(t, 0, &gatewayConfig{})
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	route := gatewayRoute{Service: "svc", Peer: target.HostPort()}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := g.check(route)
+			assert.NoError(t, err, "check failed")
+			assert.True(t, result.Healthy, "Expected healthy result")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, target.Calls(), "Concurrent checks should be shared")
+
+	// With no TTL, the next check is made once the last one completes.
+	_, err := g.check(route)
+	require.NoError(t, err, "check failed")
+	assert.Equal(t, 2, target.Calls(), "Expected a new check")
+	assert.Len(t, g.cache, 1, "Expired results should be evicted")
+}
+
+func TestLoadGatewayConfig(t *testing.T) {
+	tests := []struct {
+		contents string
+		wantErr  string
+	}{
+		{contents: `{"routes": {"kv": {"service": "keyvalue", "peer": "127.0.0.1:4532"}}}`},
+		{contents: `{"routes": {"kv": {"service": "keyvalue"}}}`, wantErr: `route "kv" must specify a service and peer`},
+		{contents: `{"routes": `, wantErr: "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		filename, cleanup := writeIDLFile(t, "routes.json", tt.contents)
+		config, err := loadGatewayConfig(filename)
+		cleanup()
+
+		if tt.wantErr != "" {
+			require.Error(t, err, "Expected %v to fail", tt.contents)
+			assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error")
+			continue
+		}
+		require.NoError(t, err, "loadGatewayConfig failed")
+		assert.Equal(t, gatewayRoute{Service: "keyvalue", Peer: "127.0.0.1:4532"}, config.Routes["kv"])
+	}
+}
+
+func TestStartGatewayBadArgs(t *testing.T) {
+	_, _, err := startGateway("", "", false, time.Second, checkOptions{})
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing host:port to fail")
+
+	_, _, err = startGateway("127.0.0.1:0", "", false, -1, checkOptions{})
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected negative TTL to fail")
+
+	_, _, err = startGateway("127.0.0.1:0", "/tcheck/does/not/exist.json", false, time.Second, checkOptions{})
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing routes to fail")
+
+	_, _, err = startGateway("127.0.0.1:0", "", true, time.Second, checkOptions{})
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected --routesOnly without routes to fail")
+
+	_, _, err = startGateway("not-a-host-port", "", false, time.Second, checkOptions{})
+	assert.Equal(t, _exitUnknown, getExitCode(err), "Expected invalid host:port to fail")
+
+	g, ln, err := startGateway("127.0.0.1:0", "", false, time.Second, checkOptions{})
+	require.NoError(t, err, "startGateway failed")
+	assert.Contains(t, g.warning(), "--routesOnly", "Gateway checking any peer should warn")
+	_, ok := ln.Addr().(*net.TCPAddr)
+	assert.True(t, ok, "Expected a TCP listener")
+	ln.Close()
+	g.close()
+}
@@ -14,8 +14,9 @@ import (
 
 // TChanMeta is the interface that defines the server handler and client interface.
 type TChanMeta interface {
-	Health(ctx thrift.Context) (*HealthStatus, error)
+	Health(ctx thrift.Context, hr *HealthRequest) (*HealthStatus, error)
 	ThriftIDL(ctx thrift.Context) (string, error)
+	VersionInfo(ctx thrift.Context) (*VersionInfo, error)
 }
 
 // Implementation of a client and service handler.
@@ -37,9 +38,11 @@ func NewTChanMetaClient(client thrift.TChanClient) TChanMeta {
 	return NewTChanMetaInheritedClient("Meta", client)
 }
 
-func (c *tchanMetaClient) Health(ctx thrift.Context) (*HealthStatus, error) {
+func (c *tchanMetaClient) Health(ctx thrift.Context, hr *HealthRequest) (*HealthStatus, error) {
 	var resp MetaHealthResult
-	args := MetaHealthArgs{}
+	args := MetaHealthArgs{
+		Hr: hr,
+	}
 	success, err := c.client.Call(ctx, c.thriftService, "health", &args, &resp)
 	if err == nil && !success {
 		switch {
@@ -65,6 +68,20 @@ func (c *tchanMetaClient) ThriftIDL(ctx thrift.Context) (string, error) {
 	return resp.GetSuccess(), err
 }
 
+func (c *tchanMetaClient) VersionInfo(ctx thrift.Context) (*VersionInfo, error) {
+	var resp MetaVersionInfoResult
+	args := MetaVersionInfoArgs{}
+	success, err := c.client.Call(ctx, c.thriftService, "versionInfo", &args, &resp)
+	if err == nil && !success {
+		switch {
+		default:
+			err = fmt.Errorf("received no result or unknown exception for versionInfo")
+		}
+	}
+
+	return resp.GetSuccess(), err
+}
+
 type tchanMetaServer struct {
 	handler TChanMeta
 }
@@ -85,6 +102,7 @@ func (s *tchanMetaServer) Methods() []string {
 	return []string{
 		"health",
 		"thriftIDL",
+		"versionInfo",
 	}
 }
 
@@ -94,6 +112,8 @@ func (s *tchanMetaServer) Handle(ctx thrift.Context, methodName string, protocol
 		return s.handleHealth(ctx, protocol)
 	case "thriftIDL":
 		return s.handleThriftIDL(ctx, protocol)
+	case "versionInfo":
+		return s.handleVersionInfo(ctx, protocol)
 
 	default:
 		return false, nil, fmt.Errorf("method %v not found in service %v", methodName, s.Service())
@@ -109,7 +129,7 @@ func (s *tchanMetaServer) handleHealth(ctx thrift.Context, protocol athrift.TPro
 	}
 
 	r, err :=
-		s.handler.Health(ctx)
+		s.handler.Health(ctx, req.Hr)
 
 	if err != nil {
 		return false, nil, err
@@ -139,3 +159,23 @@ func (s *tchanMetaServer) handleThriftIDL(ctx thrift.Context, protocol athrift.T
 
 	return err == nil, &res, nil
 }
+
+func (s *tchanMetaServer) handleVersionInfo(ctx thrift.Context, protocol athrift.TProtocol) (bool, athrift.TStruct, error) {
+	var req MetaVersionInfoArgs
+	var res MetaVersionInfoResult
+
+	if err := req.Read(protocol); err != nil {
+		return false, nil, err
+	}
+
+	r, err :=
+		s.handler.VersionInfo(ctx)
+
+	if err != nil {
+		return false, nil, err
+	} else {
+		res.Success = r
+	}
+
+	return err == nil, &res, nil
+}
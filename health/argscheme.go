@@ -0,0 +1,140 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"golang.org/x/net/context"
+)
+
+// DefaultEndpoint returns the method called by health checks using the arg
+// scheme format if Options.Endpoint is not set, which is Meta::health for
+// Thrift, and health for JSON and Raw.
+func DefaultEndpoint(format tchannel.Format) string {
+	if format == tchannel.Thrift {
+		return "Meta::health"
+	}
+	return "health"
+}
+
+// jsonHealthRequest is the request body for JSON and Raw health checks. The
+// type is process or traffic, as given to the --healthType flag.
+type jsonHealthRequest struct {
+	Type string `json:"type,omitempty"`
+}
+
+// jsonHealthStatus is the response body for JSON and Raw health checks,
+// which is interpreted the same way as a HealthStatus.
+type jsonHealthStatus struct {
+	OK      *bool  `json:"ok"`
+	Message string `json:"message"`
+}
+
+// splitThriftEndpoint splits a Thrift endpoint into the Thrift service and
+// method.
+func splitThriftEndpoint(endpoint string) (service, method string, ok bool) {
+	parts := strings.Split(endpoint, "::")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func validateEndpoint(format tchannel.Format, endpoint string) error {
+	switch format {
+	case tchannel.Thrift:
+		if _, _, ok := splitThriftEndpoint(endpoint); !ok {
+			return fmt.Errorf("thrift endpoint %q must be of the form Service::method", endpoint)
+		}
+	case tchannel.JSON, tchannel.Raw:
+	default:
+		return fmt.Errorf("unsupported arg scheme %q, must be thrift, json or raw", format)
+	}
+	return nil
+}
+
+// callJSONHealth calls the endpoint using the JSON or Raw arg scheme, and
+// decodes the response body as a HealthStatus. JSON calls send the
+// application headers as a JSON object, while Raw calls send no headers.
+func (c *Checker) callJSONHealth(ctx context.Context, target string) (*meta.HealthStatus, error) {
+	var req jsonHealthRequest
+	if c.opts.HealthType != nil {
+		req.Type = strings.ToLower(c.opts.HealthType.String())
+	}
+	// Encoding a struct or map of strings cannot fail.
+	arg3, _ := json.Marshal(req)
+
+	var arg2 []byte
+	if c.opts.Format == tchannel.JSON {
+		headers := c.opts.Headers
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		arg2, _ = json.Marshal(headers)
+	}
+
+	var (
+		call *tchannel.OutboundCall
+		err  error
+	)
+	callOpts := &tchannel.CallOptions{Format: c.opts.Format}
+	if target != "" {
+		call, err = c.ch.BeginCall(ctx, target, c.opts.ServiceName, c.opts.Endpoint, callOpts)
+	} else {
+		call, err = c.ch.GetSubChannel(c.opts.ServiceName).BeginCall(ctx, c.opts.Endpoint, callOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, body, resp, err := raw.WriteArgs(call, arg2, arg3)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ApplicationError() {
+		return nil, fmt.Errorf("application error from %v: %s", c.opts.Endpoint, body)
+	}
+	return decodeJSONHealth(body)
+}
+
+// decodeJSONHealth decodes a JSON response body, which must have an ok field.
+func decodeJSONHealth(body []byte) (*meta.HealthStatus, error) {
+	var status jsonHealthStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("invalid JSON health status %q: %v", body, err)
+	}
+	if status.OK == nil {
+		return nil, fmt.Errorf("JSON health status %q has no ok field", body)
+	}
+
+	result := &meta.HealthStatus{Ok: *status.OK}
+	if status.Message != "" {
+		result.Message = &status.Message
+	}
+	return result, nil
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"testing"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
+)
+
+// rawHealthHandler responds to raw calls with a fixed body, and records the
+// arguments of the last call.
+type rawHealthHandler struct {
+	body     string
+	appError bool
+	args     chan *raw.Args
+}
+
+func (h rawHealthHandler) Handle(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+	h.args <- args
+	return &raw.Res{IsErr: h.appError, Arg3: []byte(h.body)}, nil
+}
+
+func (h rawHealthHandler) OnError(ctx context.Context, err error) {}
+
+// renamedMetaServer serves Meta under a different Thrift service name.
+type renamedMetaServer struct {
+	thrift.TChanServer
+	name string
+}
+
+func (s renamedMetaServer) Service() string { return s.name }
+
+// emptyResultServer responds to every method with an empty result, like a
+// void method.
+type emptyResultServer struct {
+	name string
+}
+
+func (s emptyResultServer) Service() string { return s.name }
+func (emptyResultServer) Methods() []string { return []string{"health", "ping"} }
+
+func (emptyResultServer) Handle(ctx thrift.Context, method string, iprot athrift.TProtocol) (bool, athrift.TStruct, error) {
+	if err := iprot.Skip(athrift.STRUCT); err != nil {
+		return false, nil, err
+	}
+	return true, &meta.MetaHealthResult{}, nil
+}
+
+type statusHandler struct {
+	meta.TChanMeta
+	status meta.HealthStatus
+}
+
+func (h statusHandler) Health(ctx thrift.Context, hr *meta.HealthRequest) (*meta.HealthStatus, error) {
+	return &h.status, nil
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		format   tchannel.Format
+		endpoint string
+		wantErr  string
+	}{
+		{format: tchannel.Thrift, endpoint: "Meta::health"},
+		{format: tchannel.JSON, endpoint: "health"},
+		{format: tchannel.Raw, endpoint: "/health"},
+		{format: tchannel.Thrift, endpoint: "health", wantErr: "must be of the form Service::method"},
+		{format: tchannel.Thrift, endpoint: "Meta::", wantErr: "must be of the form Service::method"},
+		{format: tchannel.HTTP, endpoint: "health", wantErr: `unsupported arg scheme "http"`},
+	}
+
+	for _, tt := range tests {
+		err := validateEndpoint(tt.format, tt.endpoint)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, "Unexpected error for %v %v", tt.format, tt.endpoint)
+			continue
+		}
+		if assert.Error(t, err, "Expected error for %v %v", tt.format, tt.endpoint) {
+			assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %v %v", tt.format, tt.endpoint)
+		}
+	}
+}
+
+func TestCheckThriftEndpoint(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+
+	message := "draining"
+	handler := statusHandler{status: meta.HealthStatus{Ok: false, Message: &message}}
+	thrift.NewServer(server).Register(renamedMetaServer{meta.NewTChanMetaServer(handler), "Health"})
+
+	checker := newTestChecker(t, Options{Endpoint: "Health::health"})
+	defer checker.Close()
+
+	_, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+	assert.Equal(t, &UnhealthyError{Message: "draining"}, err, "Expected unhealthy result from the endpoint")
+
+	traffic := meta.HealthRequestType_TRAFFIC
+	checker = newTestChecker(t, Options{Endpoint: "Health::health", HealthType: &traffic})
+	defer checker.Close()
+
+	_, err = checker.Check(context.Background(), server.PeerInfo().HostPort)
+	assert.Equal(t, &UnhealthyError{Message: "draining"}, err, "Expected unhealthy result with a health type")
+}
+
+func TestCheckThriftNoResult(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+	thrift.NewServer(server).Register(emptyResultServer{"Svc"})
+	thrift.NewServer(server).Register(emptyResultServer{"Meta"})
+
+	traffic := meta.HealthRequestType_TRAFFIC
+	tests := []struct {
+		endpoint   string
+		healthType *meta.HealthRequestType
+		wantErr    string
+	}{
+		{endpoint: "Svc::ping", wantErr: "ping returned no result"},
+		{endpoint: "Meta::health", wantErr: "health returned no result"},
+		{endpoint: "Meta::health", healthType: &traffic, wantErr: "health returned no result"},
+	}
+
+	for _, tt := range tests {
+		checker := newTestChecker(t, Options{Endpoint: tt.endpoint, HealthType: tt.healthType})
+		result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+		checker.Close()
+
+		require.Error(t, err, "%v: expected an empty result to fail", tt.endpoint)
+		assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.endpoint)
+		assert.False(t, result.Healthy, "%v: result should not be healthy", tt.endpoint)
+	}
+}
+
+func TestCheckJSON(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+
+	require.NoError(t, json.Register(server, json.Handlers{
+		"health": func(ctx json.Context, req *jsonHealthRequest) (*jsonHealthStatus, error) {
+			ok := req.Type != "traffic"
+			return &jsonHealthStatus{OK: &ok, Message: "warming up"}, nil
+		},
+	}, nil), "Failed to register JSON handler")
+
+	checker := newTestChecker(t, Options{Format: tchannel.JSON, Endpoint: "health"})
+	defer checker.Close()
+
+	result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "Expected healthy JSON health check")
+	assert.Equal(t, "warming up", result.Message, "Unexpected message")
+
+	traffic := meta.HealthRequestType_TRAFFIC
+	checker = newTestChecker(t, Options{Format: tchannel.JSON, Endpoint: "health", HealthType: &traffic})
+	defer checker.Close()
+
+	_, err = checker.Check(context.Background(), server.PeerInfo().HostPort)
+	assert.Equal(t, &UnhealthyError{Message: "warming up"}, err, "Expected the health type to be sent")
+
+	checker = newTestChecker(t, Options{Format: tchannel.JSON})
+	defer checker.Close()
+
+	_, err = checker.Check(context.Background(), server.PeerInfo().HostPort)
+	assert.NoError(t, err, "Expected JSON health checks to call health by default")
+
+	checker = newTestChecker(t, Options{Format: tchannel.JSON, Endpoint: "Meta::health"})
+	defer checker.Close()
+
+	_, err = checker.Check(context.Background(), server.PeerInfo().HostPort)
+	assert.Equal(t, ClassBadRequest, Classify(err), "Expected missing endpoint to be a bad request")
+}
+
+func TestCheckRaw(t *testing.T) {
+	tests := []struct {
+		msg         string
+		body        string
+		appError    bool
+		wantErr     string
+		wantHealthy bool
+		wantMessage string
+	}{
+		{
+			msg:         "healthy",
+			body:        `{"ok": true}`,
+			wantHealthy: true,
+		},
+		{
+			msg:         "unhealthy",
+			body:        `{"ok": false, "message": "draining"}`,
+			wantMessage: "draining",
+		},
+		{
+			msg:     "not JSON",
+			body:    "OK",
+			wantErr: `invalid JSON health status "OK"`,
+		},
+		{
+			msg:     "missing ok",
+			body:    `{"message": "hi"}`,
+			wantErr: "has no ok field",
+		},
+		{
+			msg:      "application error",
+			body:     "boom",
+			appError: true,
+			wantErr:  "application error from health: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		server := setupServer(t, nil)
+		handler := rawHealthHandler{body: tt.body, appError: tt.appError, args: make(chan *raw.Args, 1)}
+		server.Register(raw.Wrap(handler), "health")
+
+		checker := newTestChecker(t, Options{Format: tchannel.Raw, Endpoint: "health"})
+		result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+		checker.Close()
+		server.Close()
+
+		args := <-handler.args
+		assert.Equal(t, tchannel.Raw, args.Format, "%v: unexpected arg scheme", tt.msg)
+		assert.Empty(t, args.Arg2, "%v: raw calls should have no headers", tt.msg)
+		assert.Equal(t, "{}", string(args.Arg3), "%v: unexpected request body", tt.msg)
+
+		switch {
+		case tt.wantErr != "":
+			callErr, ok := err.(*CallError)
+			if assert.True(t, ok, "%v: expected CallError, got %v", tt.msg, err) {
+				assert.Contains(t, callErr.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			}
+		case tt.wantHealthy:
+			assert.NoError(t, err, "%v: expected healthy", tt.msg)
+		default:
+			assert.Equal(t, &UnhealthyError{Message: tt.wantMessage}, err, "%v: expected unhealthy", tt.msg)
+		}
+		assert.Equal(t, tt.wantHealthy, result.Healthy, "%v: unexpected result", tt.msg)
+	}
+}
+
+func TestDecodeJSONHealth(t *testing.T) {
+	status, err := decodeJSONHealth([]byte(`{"ok": true, "message": "fine", "extra": 1}`))
+	require.NoError(t, err, "Failed to decode health status")
+	assert.True(t, status.Ok, "Unexpected ok")
+	assert.Equal(t, "fine", status.GetMessage(), "Unexpected message")
+
+	status, err = decodeJSONHealth([]byte(`{"ok": false}`))
+	require.NoError(t, err, "Failed to decode health status")
+	assert.Nil(t, status.Message, "Empty message should be unset")
+
+	_, err = decodeJSONHealth([]byte(`{"ok": "yes"}`))
+	assert.Error(t, err, "Expected ok that isn't a bool to fail")
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"net"
+	"strings"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/hyperbahn"
+)
+
+// NewHyperbahnChannel returns a channel that routes calls through the
+// Hyperbahn relays listed in hostsFile.
+func NewHyperbahnChannel(serviceName, hostsFile string) (*tchannel.Channel, error) {
+	ch, err := tchannel.NewChannel(serviceName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Hyperbahn client adds the relays to the channel's peers, so calls
+	// to any service on the channel are routed through Hyperbahn.
+	config := hyperbahn.Configuration{InitialNodesFile: hostsFile}
+	if _, err := hyperbahn.NewClient(ch, config, nil); err != nil {
+		ch.Close()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// ConnectedPeer returns the host:port of a peer that the channel has an
+// outbound connection to. For a channel that has only made a single call,
+// this is the peer that the call was sent to.
+func ConnectedPeer(ch *tchannel.Channel) string {
+	for hostPort, p := range ch.Peers().Copy() {
+		if _, outbound := p.NumConnections(); outbound > 0 {
+			return hostPort
+		}
+	}
+	return ""
+}
+
+// RemapLocalhost replaces the host "localhost" with the best public IP on the
+// host, as TChannel tools do.
+//
+// The protocol assumes services only listen on a single host:port, so the
+// client libraries tend to listen on a single interface. This localhost remapping
+// makes it easier for users to health check an instance on the current host
+// without having to find the public IP of the machine.
+func RemapLocalhost(hostPort string) string {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return hostPort
+	}
+
+	if !strings.EqualFold(host, "localhost") {
+		return hostPort
+	}
+
+	ip, err := tchannel.ListenIP()
+	if err != nil {
+		return hostPort
+	}
+
+	return net.JoinHostPort(ip.String(), port)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+func TestRemapLocalhost(t *testing.T) {
+	ip, err := tchannel.ListenIP()
+	require.NoError(t, err, "Failed to get ListenIP")
+
+	tests := []struct {
+		hostPort string
+		want     string
+	}{
+		{
+			hostPort: "localhost:1:2",
+			want:     "localhost:1:2",
+		},
+		{
+			hostPort: "1.1.1.1:2",
+			want:     "1.1.1.1:2",
+		},
+		{
+			hostPort: "localhost:2",
+			want:     ip.String() + ":2",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, RemapLocalhost(tt.hostPort), "Remap %q", tt.hostPort)
+	}
+}
+
+func TestNewHyperbahnChannelMissingHostsFile(t *testing.T) {
+	_, err := NewHyperbahnChannel("tcheck", "/tcheck/does/not/exist.json")
+	assert.Error(t, err, "Expected missing hosts file to fail")
+}
+
+func TestConnectedPeer(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	ch, err := tchannel.NewChannel("tcheck", nil)
+	require.NoError(t, err, "Failed to create channel")
+	defer ch.Close()
+	assert.Empty(t, ConnectedPeer(ch), "No peers before any calls")
+
+	// Health checks without a target are routed through the channel's peers.
+	ch.Peers().Add(server.PeerInfo().HostPort)
+	checker, err := NewChecker(Options{ServiceName: "svc", Channel: ch})
+	require.NoError(t, err, "Failed to create checker")
+	result, err := checker.Check(context.Background(), "")
+	require.NoError(t, err, "Health check failed")
+	assert.Equal(t, server.PeerInfo().HostPort, ConnectedPeer(ch), "Unexpected connected peer")
+	assert.Equal(t, server.PeerInfo().HostPort, result.Relay, "Unexpected relay")
+}
@@ -0,0 +1,508 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package health health checks TChannel services using Meta::health.
+//
+// A Checker makes health checks using a single channel, either directly to a
+// peer, or routed through the channel's peers, such as Hyperbahn relays:
+//
+//	checker, err := health.NewChecker(health.Options{ServiceName: "keyvalue"})
+//	if err != nil {
+//		return err
+//	}
+//	defer checker.Close()
+//
+//	result, err := checker.Check(ctx, "10.0.0.1:4532")
+//
+// Check returns an *UnhealthyError if the peer reports itself unhealthy, or a
+// *CallError with the ErrorClass of the failure if the health check fails,
+// including a peer that's healthy but slower than Options.MaxLatency.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
+)
+
+// DefaultTimeout is the timeout for each health check attempt if
+// Options.Timeout is not set.
+const DefaultTimeout = time.Second
+
+// _channelServiceName is the service name of channels created by a Checker
+// if Options.Caller is not set.
+const _channelServiceName = "tcheck"
+
+// Options configure a Checker.
+type Options struct {
+	// ServiceName is the service to health check.
+	ServiceName string
+
+	// Timeout is the timeout for each attempt. The context passed to Check
+	// limits the time for all attempts, including retries.
+	Timeout time.Duration
+
+	// HealthType is the type of health check to request. If it's nil, no
+	// HealthRequest is sent, which peers treat as a process health check.
+	HealthType *meta.HealthRequestType
+
+	// Format is the arg scheme of the health check call, which is Thrift if
+	// it's not set. JSON and Raw health checks send a JSON request body, and
+	// the response body is interpreted as a JSON HealthStatus, e.g.
+	// {"ok": false, "message": "draining"}.
+	Format tchannel.Format
+
+	// Endpoint is the method called by the health check, which is
+	// DefaultEndpoint(Format) if it's not set. Thrift endpoints must be of
+	// the form "Service::method", and take the same arguments and result as
+	// Meta::health.
+	Endpoint string
+
+	// ShardKey, RoutingKey and RoutingDelegate are sent as transport headers
+	// with the health check call, for relays that route by them.
+	ShardKey        string
+	RoutingKey      string
+	RoutingDelegate string
+
+	// Headers are application headers sent with the health check call. Raw
+	// health checks have no application headers, so they're not sent.
+	Headers map[string]string
+
+	// Caller is the service name of the channel created by the Checker, which
+	// peers see as the caller of the health check, and the process name sent
+	// when connecting to measure timing or ping. It's "tcheck" if it's not
+	// set, and Channel's service name if Channel is set.
+	Caller string
+
+	// Retry is the policy for retrying failed health checks.
+	Retry RetryPolicy
+
+	// RemapLocalhost remaps targets on "localhost" to the host's public IP,
+	// as TChannel tools do. See RemapLocalhost.
+	RemapLocalhost bool
+
+	// Channel is the channel used to make health checks. If it's nil, the
+	// Checker creates a channel, which is closed by Close.
+	Channel *tchannel.Channel
+
+	// HostsFile is a Hyperbahn hosts file used to route health checks that
+	// don't specify a target. It's only used if Channel is nil.
+	HostsFile string
+
+	// MaxLatency is the longest a healthy peer may take to respond to the
+	// health check or ping, after which it fails with a *SlowError. It's not
+	// checked if it's zero.
+	MaxLatency time.Duration
+
+	// ExpectProcess is the process name the target must report in the
+	// TChannel init handshake, ignoring the "[pid]" suffix, otherwise the
+	// health check fails with a *ProcessMismatchError. It's ignored for
+	// health checks without a target.
+	ExpectProcess string
+
+	// MeasureTiming sets the time taken to resolve, connect and handshake in
+	// each Attempt's Timing, by making a separate connection to the target before
+	// the health check. It's ignored for health checks without a target.
+	MeasureTiming bool
+}
+
+// Result is the result of a health check.
+type Result struct {
+	// Peer is the target that was health checked, and ResolvedPeer is the
+	// host:port that the health check was sent to. Both are empty if the
+	// health check was routed through the channel's peers.
+	Peer         string
+	ResolvedPeer string
+
+	// Relay is the peer that the health check was routed through, if it
+	// didn't specify a target. This is only accurate for a channel that's
+	// only used by a single Checker.
+	Relay string
+
+	ServiceName string
+	Healthy     bool
+	Message     string
+
+	// Latency is the total time taken, including retries.
+	Latency time.Duration
+
+	// Attempts are the outcomes of each attempt, including retries.
+	Attempts []Attempt
+
+	// HealthTypeIgnored is set if the peer failed to decode the requested
+	// health check type, and a health check without a type succeeded instead.
+	HealthTypeIgnored bool
+
+	// Identity is how the target identified itself when connecting. It's
+	// empty if the health check didn't have a target, or couldn't connect.
+	Identity Identity
+}
+
+// Attempt is the outcome of a single attempt of a health check.
+type Attempt struct {
+	Err     error
+	Latency time.Duration
+	Timing  Timing
+}
+
+// Timing is the time taken by each phase of an attempt.
+type Timing struct {
+	// DNS, Connect and Handshake are the time taken to resolve the target's
+	// host, establish a TCP connection and complete the TChannel init
+	// handshake. They're only set if Options.MeasureTiming is set, and DNS is
+	// zero if the target's host is an IP address.
+	DNS       time.Duration
+	Connect   time.Duration
+	Handshake time.Duration
+
+	// Call is the round trip time of the Meta::health call. For health checks
+	// with a target, it doesn't include connecting to the target.
+	Call time.Duration
+
+	// Ping is the round trip time of a ping, which is only set by Ping.
+	Ping time.Duration
+}
+
+// Total returns the time taken by all phases.
+func (t Timing) Total() time.Duration {
+	return t.DNS + t.Connect + t.Handshake + t.Call + t.Ping
+}
+
+// Checker health checks a service. It's safe for concurrent use.
+type Checker struct {
+	opts        Options
+	ch          *tchannel.Channel
+	ownsChannel bool
+}
+
+// NewChecker returns a Checker with the given options.
+func NewChecker(opts Options) (*Checker, error) {
+	if opts.ServiceName == "" {
+		return nil, errors.New("must specify a service name")
+	}
+	if opts.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative, got %v", opts.Timeout)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxLatency < 0 {
+		return nil, fmt.Errorf("max latency must not be negative, got %v", opts.MaxLatency)
+	}
+	if opts.Format == "" {
+		opts.Format = tchannel.Thrift
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint(opts.Format)
+	}
+	switch {
+	case opts.Channel != nil:
+		opts.Caller = opts.Channel.ServiceName()
+	case opts.Caller == "":
+		opts.Caller = _channelServiceName
+	}
+	if err := validateEndpoint(opts.Format, opts.Endpoint); err != nil {
+		return nil, err
+	}
+	if err := opts.Retry.validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.Channel != nil {
+		return &Checker{opts: opts, ch: opts.Channel}, nil
+	}
+
+	var (
+		ch  *tchannel.Channel
+		err error
+	)
+	if opts.HostsFile != "" {
+		ch, err = NewHyperbahnChannel(opts.Caller, opts.HostsFile)
+	} else {
+		ch, err = tchannel.NewChannel(opts.Caller, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{opts: opts, ch: ch, ownsChannel: true}, nil
+}
+
+// Close closes the channel if it was created by the Checker.
+func (c *Checker) Close() {
+	if c.ownsChannel {
+		c.ch.Close()
+	}
+}
+
+// Check calls Meta::health on the service at target, or routes the health
+// check through the channel's peers if target is empty. It returns an error
+// if the service is not healthy.
+func (c *Checker) Check(ctx context.Context, target string) (Result, error) {
+	result := Result{Peer: target, ServiceName: c.opts.ServiceName}
+
+	var clientOpts *thrift.ClientOptions
+	if target != "" {
+		result.ResolvedPeer = target
+		if c.opts.RemapLocalhost {
+			result.ResolvedPeer = RemapLocalhost(target)
+		}
+		clientOpts = &thrift.ClientOptions{HostPort: result.ResolvedPeer}
+	}
+	client := thrift.NewClient(c.ch, c.opts.ServiceName, clientOpts)
+
+	var status *meta.HealthStatus
+	started := time.Now()
+	result.Attempts = c.opts.Retry.retry(ctx, func(a *Attempt) error {
+		var err error
+		status, err = c.attempt(ctx, client, result.ResolvedPeer, a, &result)
+		return err
+	})
+	result.Latency = time.Since(started)
+	if target == "" {
+		result.Relay = ConnectedPeer(c.ch)
+	}
+
+	if err := result.Attempts[len(result.Attempts)-1].Err; err != nil {
+		return result, &CallError{Class: Classify(err), Err: err}
+	}
+
+	result.Healthy = status.Ok
+	result.Message = status.GetMessage()
+	if !result.Healthy {
+		return result, &UnhealthyError{Message: result.Message}
+	}
+	return result, nil
+}
+
+// Ping connects to the target, and sends a TChannel ping instead of calling
+// Meta::health, so that a peer that's up can be told apart from one that's
+// down, even if it has no Meta::health handler. The result is healthy if the
+// peer responds to the ping, and each Attempt's Timing is set. Pings use a
+// separate connection from the channel, and the Retry policy.
+func (c *Checker) Ping(ctx context.Context, target string) (Result, error) {
+	result := Result{Peer: target, ResolvedPeer: target, ServiceName: c.opts.ServiceName}
+	if target == "" {
+		return result, errors.New("must specify a target to ping")
+	}
+	if c.opts.RemapLocalhost {
+		result.ResolvedPeer = RemapLocalhost(target)
+	}
+
+	started := time.Now()
+	result.Attempts = c.opts.Retry.retry(ctx, func(a *Attempt) error {
+		return c.ping(ctx, result.ResolvedPeer, &a.Timing, &result)
+	})
+	result.Latency = time.Since(started)
+
+	if err := result.Attempts[len(result.Attempts)-1].Err; err != nil {
+		return result, &CallError{Class: Classify(err), Err: err}
+	}
+	result.Healthy = true
+	return result, nil
+}
+
+// processName returns the process name sent when connecting using a probe,
+// which is the same as the channel's.
+func (c *Checker) processName() string {
+	return fmt.Sprintf("%v[%v]", c.opts.Caller, os.Getpid())
+}
+
+// ping makes a single ping, limited by the timeout.
+func (c *Checker) ping(ctx context.Context, target string, t *Timing, result *Result) error {
+	tctx, cancel := tchannel.NewContextBuilder(c.opts.Timeout).SetParentContext(ctx).Build()
+	defer cancel()
+
+	probe, err := dialProbe(tctx, target, t)
+	if err != nil {
+		return err
+	}
+	defer probe.Close()
+
+	headers, err := probe.handshake(c.processName(), t)
+	if err != nil {
+		return err
+	}
+	result.Identity = identityFromHeaders(headers, probe.conn.RemoteAddr().String())
+	if err := checkProcess(result.Identity, c.opts.ExpectProcess); err != nil {
+		return err
+	}
+	if err := probe.ping(t); err != nil {
+		return err
+	}
+
+	if c.opts.MaxLatency > 0 && t.Ping > c.opts.MaxLatency {
+		return &SlowError{Latency: t.Ping, MaxLatency: c.opts.MaxLatency}
+	}
+	return nil
+}
+
+// attempt makes a single health check, connecting to the target first, if
+// any, so that the time taken by the call can be measured on its own, and
+// the target's identity can be checked. It
+// fails with a *SlowError if the peer is healthy, but took longer than
+// MaxLatency to respond.
+func (c *Checker) attempt(ctx context.Context, client thrift.TChanClient, target string, a *Attempt, result *Result) (*meta.HealthStatus, error) {
+	if target != "" {
+		conn, err := c.connect(ctx, target, &a.Timing)
+		if err != nil {
+			return nil, err
+		}
+		result.Identity = identityFromPeerInfo(conn.RemotePeerInfo())
+		if err := checkProcess(result.Identity, c.opts.ExpectProcess); err != nil {
+			return nil, err
+		}
+	}
+
+	started := time.Now()
+	status, err := c.callHealth(ctx, client, result)
+	a.Timing.Call = time.Since(started)
+	if err != nil || !status.Ok {
+		return status, err
+	}
+
+	if c.opts.MaxLatency > 0 && a.Timing.Call > c.opts.MaxLatency {
+		return status, &SlowError{Latency: a.Timing.Call, MaxLatency: c.opts.MaxLatency}
+	}
+	return status, nil
+}
+
+// connect establishes the channel's connection to target, if it doesn't
+// already have one. If MeasureTiming is set, a separate connection is made
+// first, to measure the time taken by each phase of connecting.
+func (c *Checker) connect(ctx context.Context, target string, t *Timing) (*tchannel.Connection, error) {
+	tctx, cancel := tchannel.NewContextBuilder(c.opts.Timeout).SetParentContext(ctx).Build()
+	defer cancel()
+
+	if c.opts.MeasureTiming {
+		probe, err := dialProbe(tctx, target, t)
+		if err != nil {
+			return nil, err
+		}
+		_, err = probe.handshake(c.processName(), t)
+		probe.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.ch.RootPeers().GetOrAdd(target).GetConnection(tctx)
+}
+
+// callHealth makes a single health check call. TChannel's own retries are
+// disabled, so that only the retry policy decides which errors are retried.
+func (c *Checker) callHealth(ctx context.Context, client thrift.TChanClient, result *Result) (*meta.HealthStatus, error) {
+	tctx, cancel := tchannel.NewContextBuilder(c.opts.Timeout).
+		SetParentContext(ctx).
+		SetRetryOptions(&tchannel.RetryOptions{RetryOn: tchannel.RetryNever}).
+		SetShardKey(c.opts.ShardKey).
+		SetRoutingKey(c.opts.RoutingKey).
+		SetRoutingDelegate(c.opts.RoutingDelegate).
+		SetHeaders(c.opts.Headers).
+		Build()
+	defer cancel()
+
+	if c.opts.Format != tchannel.Thrift {
+		return c.callJSONHealth(tctx, result.ResolvedPeer)
+	}
+
+	service, method, _ := splitThriftEndpoint(c.opts.Endpoint)
+	if c.opts.HealthType == nil {
+		return callLegacyHealth(tctx, client, service, method)
+	}
+
+	var resp meta.MetaHealthResult
+	args := &meta.MetaHealthArgs{Hr: &meta.HealthRequest{Type: c.opts.HealthType}}
+	success, err := client.Call(tctx, service, method, args, &resp)
+	if rejectedRequest(err) {
+		// Peers with a stricter Meta IDL may fail to decode the HealthRequest,
+		// so fall back to a health check without any arguments. Peers with an
+		// older Meta IDL skip the unknown argument and never get here.
+		status, legacyErr := callLegacyHealth(tctx, client, service, method)
+		if legacyErr != nil {
+			return nil, err
+		}
+		result.HealthTypeIgnored = true
+		return status, nil
+	}
+	return healthStatus(method, &resp, success, err)
+}
+
+// healthStatus returns the HealthStatus from the result of calling method. It
+// returns an error if the call failed, or returned no HealthStatus, such as
+// when the method is void.
+func healthStatus(method string, resp *meta.MetaHealthResult, success bool, err error) (*meta.HealthStatus, error) {
+	if err != nil {
+		return nil, err
+	}
+	if !success {
+		return nil, fmt.Errorf("received no result or unknown exception for %v", method)
+	}
+	if resp.Success == nil {
+		return nil, fmt.Errorf("%v returned no result", method)
+	}
+	return resp.Success, nil
+}
+
+// legacyHealthArgs are the arguments for Meta::health before HealthRequest
+// was added to the Meta IDL, which are accepted by all peers.
+type legacyHealthArgs struct{}
+
+func (legacyHealthArgs) Read(iprot athrift.TProtocol) error {
+	return iprot.Skip(athrift.STRUCT)
+}
+
+func (legacyHealthArgs) Write(oprot athrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("health_args"); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return err
+	}
+	return oprot.WriteStructEnd()
+}
+
+// callLegacyHealth calls Meta::health, or another Thrift endpoint with the
+// same signature, without a HealthRequest.
+func callLegacyHealth(ctx thrift.Context, client thrift.TChanClient, service, method string) (*meta.HealthStatus, error) {
+	var resp meta.MetaHealthResult
+	success, err := client.Call(ctx, service, method, legacyHealthArgs{}, &resp)
+	return healthStatus(method, &resp, success, err)
+}
+
+// rejectedRequest returns whether err indicates that the peer could not
+// decode the arguments to the call. TChannel servers return a bad request
+// with the Thrift decoding error, which is distinguished from a missing
+// handler by the "read error" that generated code prefixes it with.
+func rejectedRequest(err error) bool {
+	se, ok := err.(tchannel.SystemError)
+	if !ok || se.Code() != tchannel.ErrCodeBadRequest {
+		return false
+	}
+	return strings.Contains(se.Message(), "read error")
+}
@@ -0,0 +1,543 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+	"github.com/uber/tchannel-go/testutils"
+	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
+)
+
+func healthOk(ctx thrift.Context) (bool, string) {
+	return true, ""
+}
+
+func setupServer(t *testing.T, fn thrift.HealthFunc) *tchannel.Channel {
+	opts := testutils.NewOpts().
+		SetServiceName("svc").
+		DisableLogVerification()
+	ch := testutils.NewServer(t, opts)
+
+	if fn != nil {
+		thrift.NewServer(ch).RegisterHealthHandler(fn)
+	}
+	return ch
+}
+
+// busyHandler returns a busy error for the first busyCalls health checks.
+type busyHandler struct {
+	meta.TChanMeta
+
+	calls     *int32
+	busyCalls int32
+}
+
+func (h busyHandler) Health(ctx thrift.Context, hr *meta.HealthRequest) (*meta.HealthStatus, error) {
+	if atomic.AddInt32(h.calls, 1) <= h.busyCalls {
+		return nil, tchannel.ErrServerBusy
+	}
+	return &meta.HealthStatus{Ok: true}, nil
+}
+
+func newTestChecker(t *testing.T, opts Options) *Checker {
+	opts.ServiceName = "svc"
+	checker, err := NewChecker(opts)
+	require.NoError(t, err, "Failed to create checker")
+	return checker
+}
+
+func TestNewCheckerBadOptions(t *testing.T) {
+	tests := []struct {
+		msg  string
+		opts Options
+	}{
+		{"no service name", Options{}},
+		{"negative timeout", Options{ServiceName: "svc", Timeout: -1}},
+		{"negative retries", Options{ServiceName: "svc", Retry: RetryPolicy{Retries: -1}}},
+		{"negative max latency", Options{ServiceName: "svc", MaxLatency: -1}},
+		{"missing hosts file", Options{ServiceName: "svc", HostsFile: "/tcheck/does/not/exist.json"}},
+	}
+
+	for _, tt := range tests {
+		_, err := NewChecker(tt.opts)
+		assert.Error(t, err, "%v: expected NewChecker to fail", tt.msg)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	healthy := setupServer(t, healthOk)
+	defer healthy.Close()
+
+	unhealthy := setupServer(t, func(ctx thrift.Context) (bool, string) {
+		return false, "draining"
+	})
+	defer unhealthy.Close()
+
+	noHandler := setupServer(t, nil)
+	defer noHandler.Close()
+
+	checker := newTestChecker(t, Options{})
+	defer checker.Close()
+
+	result, err := checker.Check(context.Background(), healthy.PeerInfo().HostPort)
+	require.NoError(t, err, "Expected healthy peer")
+	assert.True(t, result.Healthy, "Result should be healthy")
+	assert.Equal(t, healthy.PeerInfo().HostPort, result.ResolvedPeer, "Unexpected resolved peer")
+	assert.Len(t, result.Attempts, 1, "Unexpected attempts")
+
+	result, err = checker.Check(context.Background(), unhealthy.PeerInfo().HostPort)
+	require.IsType(t, &UnhealthyError{}, err, "Expected unhealthy error")
+	assert.False(t, result.Healthy, "Result should be unhealthy")
+	assert.Equal(t, "draining", result.Message, "Unexpected message")
+
+	_, err = checker.Check(context.Background(), noHandler.PeerInfo().HostPort)
+	require.IsType(t, &CallError{}, err, "Expected call error")
+	assert.Equal(t, ClassBadRequest, Classify(err), "Unexpected error class")
+}
+
+func TestCheckConnectionRefused(t *testing.T) {
+	// Find a port that nothing is listening on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	ln.Close()
+
+	checker := newTestChecker(t, Options{})
+	defer checker.Close()
+
+	_, err = checker.Check(context.Background(), ln.Addr().String())
+	assert.Equal(t, ClassConnectionRefused, Classify(err), "Unexpected error class for %v", err)
+}
+
+func TestCheckHealthType(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+	thrift.NewServer(server).Register(meta.NewTChanMetaServer(busyHandler{calls: new(int32)}))
+
+	traffic := meta.HealthRequestType_TRAFFIC
+	checker := newTestChecker(t, Options{HealthType: &traffic})
+	defer checker.Close()
+
+	result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "Health check failed")
+	assert.False(t, result.HealthTypeIgnored, "HealthRequest should not be rejected")
+}
+
+// legacyMetaServer serves Meta::health using an IDL without HealthRequest.
+// Generated code for the older IDL skips the unknown argument, while strict
+// servers fail to decode it, like peers with a mismatched IDL may.
+type legacyMetaServer struct {
+	calls  *int32
+	strict bool
+}
+
+func (legacyMetaServer) Service() string   { return "Meta" }
+func (legacyMetaServer) Methods() []string { return []string{"health"} }
+
+func (s legacyMetaServer) Handle(ctx thrift.Context, method string, iprot athrift.TProtocol) (bool, athrift.TStruct, error) {
+	atomic.AddInt32(s.calls, 1)
+	if s.strict {
+		if _, err := iprot.ReadStructBegin(); err != nil {
+			return false, nil, err
+		}
+		_, _, id, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return false, nil, err
+		}
+		if id != 0 {
+			return false, nil, athrift.PrependError(fmt.Sprintf("*meta.MetaHealthArgs field %d read error: ", id),
+				athrift.NewTProtocolExceptionWithType(athrift.INVALID_DATA, errors.New("unknown field")))
+		}
+		if err := iprot.ReadStructEnd(); err != nil {
+			return false, nil, err
+		}
+	} else if err := iprot.Skip(athrift.STRUCT); err != nil {
+		return false, nil, err
+	}
+	return true, &meta.MetaHealthResult{Success: &meta.HealthStatus{Ok: true}}, nil
+}
+
+// errorHandler returns an error from every health check.
+type errorHandler struct {
+	meta.TChanMeta
+
+	calls *int32
+}
+
+func (h errorHandler) Health(ctx thrift.Context, hr *meta.HealthRequest) (*meta.HealthStatus, error) {
+	atomic.AddInt32(h.calls, 1)
+	return nil, errors.New("database unavailable")
+}
+
+func TestCheckHealthTypeFallback(t *testing.T) {
+	tests := []struct {
+		msg         string
+		server      func(calls *int32) thrift.TChanServer
+		wantCalls   int32
+		wantIgnored bool
+		wantClass   ErrorClass
+	}{
+		{
+			msg:       "older IDL ignores the type",
+			server:    func(calls *int32) thrift.TChanServer { return legacyMetaServer{calls: calls} },
+			wantCalls: 1,
+		},
+		{
+			msg:         "strict IDL fails to decode the type",
+			server:      func(calls *int32) thrift.TChanServer { return legacyMetaServer{calls: calls, strict: true} },
+			wantCalls:   2,
+			wantIgnored: true,
+		},
+		{
+			msg:       "handler error",
+			server:    func(calls *int32) thrift.TChanServer { return meta.NewTChanMetaServer(errorHandler{calls: calls}) },
+			wantCalls: 1,
+			wantClass: ClassUnknown,
+		},
+		{
+			msg:       "no handler",
+			wantClass: ClassBadRequest,
+		},
+	}
+
+	traffic := meta.HealthRequestType_TRAFFIC
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			server := setupServer(t, nil)
+			defer server.Close()
+
+			calls := new(int32)
+			if tt.server != nil {
+				thrift.NewServer(server).Register(tt.server(calls))
+			}
+
+			checker := newTestChecker(t, Options{HealthType: &traffic})
+			defer checker.Close()
+
+			result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+			assert.Equal(t, tt.wantCalls, atomic.LoadInt32(calls), "Unexpected number of calls")
+			assert.Equal(t, tt.wantIgnored, result.HealthTypeIgnored, "Unexpected HealthTypeIgnored")
+			if tt.wantClass != "" {
+				require.Error(t, err, "Expected health check to fail")
+				assert.Equal(t, tt.wantClass, Classify(err), "Unexpected error class for %v", err)
+				return
+			}
+			require.NoError(t, err, "Expected health check to succeed")
+			assert.True(t, result.Healthy, "Result should be healthy")
+		})
+	}
+}
+
+func TestCheckRetries(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+	thrift.NewServer(server).Register(meta.NewTChanMetaServer(busyHandler{calls: new(int32), busyCalls: 2}))
+
+	checker := newTestChecker(t, Options{
+		Retry: RetryPolicy{Retries: 2, Backoff: time.Millisecond, On: []ErrorClass{ClassBusy}},
+	})
+	defer checker.Close()
+
+	result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "Expected health check to succeed after retries")
+	require.Len(t, result.Attempts, 3, "Unexpected number of attempts")
+	assert.Equal(t, tchannel.ErrServerBusy, result.Attempts[0].Err, "Unexpected first attempt")
+}
+
+func TestCheckContextDeadline(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+	thrift.NewServer(server).Register(meta.NewTChanMetaServer(busyHandler{calls: new(int32), busyCalls: 100}))
+
+	checker := newTestChecker(t, Options{
+		Retry: RetryPolicy{Retries: 100, Backoff: 10 * time.Millisecond, On: []ErrorClass{ClassBusy}},
+	})
+	defer checker.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result, err := checker.Check(ctx, server.PeerInfo().HostPort)
+	assert.Equal(t, ClassBusy, Classify(err), "Unexpected error class for %v", err)
+	assert.True(t, len(result.Attempts) < 10, "Retries should stop at the deadline, got %v attempts", len(result.Attempts))
+}
+
+func TestCheckMaxLatency(t *testing.T) {
+	server := setupServer(t, func(ctx thrift.Context) (bool, string) {
+		time.Sleep(20 * time.Millisecond)
+		return true, ""
+	})
+	defer server.Close()
+
+	checker := newTestChecker(t, Options{MaxLatency: 5 * time.Millisecond})
+	defer checker.Close()
+
+	result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.IsType(t, &CallError{}, err, "Expected call error")
+	assert.Equal(t, ClassSlow, Classify(err), "Unexpected error class")
+	require.IsType(t, &SlowError{}, err.(*CallError).Err, "Expected slow error")
+	assert.True(t, result.Attempts[0].Timing.Call >= 20*time.Millisecond, "Call time should include the handler")
+
+	checker = newTestChecker(t, Options{MaxLatency: time.Second})
+	defer checker.Close()
+
+	_, err = checker.Check(context.Background(), server.PeerInfo().HostPort)
+	assert.NoError(t, err, "Expected health check within the max latency to succeed")
+}
+
+func TestCheckMeasureTiming(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	checker := newTestChecker(t, Options{MeasureTiming: true})
+	defer checker.Close()
+
+	result, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "Expected healthy peer")
+	timing := result.Attempts[0].Timing
+	assert.Zero(t, timing.DNS, "DNS should not be measured for an IP address")
+	assert.True(t, timing.Connect > 0, "Connect should be measured")
+	assert.True(t, timing.Handshake > 0, "Handshake should be measured")
+	assert.True(t, timing.Call > 0, "Call should be measured")
+	assert.Equal(t, timing.Connect+timing.Handshake+timing.Call, timing.Total(), "Unexpected total")
+
+	checker = newTestChecker(t, Options{})
+	defer checker.Close()
+
+	result, err = checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "Expected healthy peer")
+	timing = result.Attempts[0].Timing
+	assert.Zero(t, timing.Connect, "Connect should only be measured with MeasureTiming")
+	assert.True(t, timing.Call > 0, "Call should always be measured")
+}
+
+func TestPing(t *testing.T) {
+	// The server has no Meta::health handler, but responds to pings.
+	server := setupServer(t, nil)
+	defer server.Close()
+
+	checker := newTestChecker(t, Options{})
+	defer checker.Close()
+
+	result, err := checker.Ping(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "Ping failed")
+	assert.True(t, result.Healthy, "Result should be healthy")
+	require.Len(t, result.Attempts, 1, "Unexpected attempts")
+	timing := result.Attempts[0].Timing
+	assert.True(t, timing.Handshake > 0, "Handshake should be measured")
+	assert.True(t, timing.Ping > 0, "Ping should be measured")
+	assert.Zero(t, timing.Call, "Ping should not call Meta::health")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	ln.Close()
+
+	_, err = checker.Ping(context.Background(), ln.Addr().String())
+	assert.Equal(t, ClassConnectionRefused, Classify(err), "Unexpected error class for %v", err)
+
+	_, err = checker.Ping(context.Background(), "")
+	assert.Error(t, err, "Ping should require a target")
+
+	checker = newTestChecker(t, Options{MaxLatency: time.Nanosecond})
+	defer checker.Close()
+
+	_, err = checker.Ping(context.Background(), server.PeerInfo().HostPort)
+	assert.Equal(t, ClassSlow, Classify(err), "Unexpected error class for %v", err)
+}
+
+func TestProbeProcessName(t *testing.T) {
+	callerCh, err := tchannel.NewChannel("deployer", nil)
+	require.NoError(t, err, "Failed to create channel")
+	defer callerCh.Close()
+
+	tests := []struct {
+		msg  string
+		opts Options
+		want string
+	}{
+		{"default", Options{}, "tcheck"},
+		{"caller", Options{Caller: "health-tester"}, "health-tester"},
+		{"channel", Options{Channel: callerCh}, "deployer"},
+	}
+
+	for _, tt := range tests {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err, "Failed to listen")
+
+		// Record the process name in the init request, then hang up.
+		processNames := make(chan string, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			payload, err := (&probeConn{conn: conn}).read(_frameInitReq)
+			if err != nil {
+				processNames <- err.Error()
+				return
+			}
+			headers, _ := decodeInit(payload)
+			processNames <- headers[tchannel.InitParamProcessName]
+		}()
+
+		checker := newTestChecker(t, tt.opts)
+		checker.Ping(context.Background(), ln.Addr().String())
+		checker.Close()
+		ln.Close()
+
+		assert.Equal(t, fmt.Sprintf("%v[%v]", tt.want, os.Getpid()), <-processNames, "%v: unexpected process name", tt.msg)
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	// A peer that accepts connections, but never completes the handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	defer ln.Close()
+
+	checker := newTestChecker(t, Options{Timeout: 50 * time.Millisecond})
+	defer checker.Close()
+
+	_, err = checker.Ping(context.Background(), ln.Addr().String())
+	assert.Equal(t, ClassTimeout, Classify(err), "Unexpected error class for %v", err)
+}
+
+func TestCheckIdentity(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+	hostPort := server.PeerInfo().HostPort
+
+	checker := newTestChecker(t, Options{})
+	defer checker.Close()
+
+	result, err := checker.Check(context.Background(), hostPort)
+	require.NoError(t, err, "Expected healthy peer")
+	assert.Equal(t, hostPort, result.Identity.HostPort, "Unexpected host:port")
+	assert.Equal(t, server.PeerInfo().ProcessName, result.Identity.ProcessName, "Unexpected process name")
+	assert.Equal(t, "go", result.Identity.Language, "Unexpected language")
+	assert.Equal(t, tchannel.VersionInfo, result.Identity.TChannelVersion, "Unexpected TChannel version")
+
+	result, err = checker.Ping(context.Background(), hostPort)
+	require.NoError(t, err, "Ping failed")
+	assert.Equal(t, server.PeerInfo().ProcessName, result.Identity.ProcessName, "Unexpected process name for ping")
+
+	wrongProcess := newTestChecker(t, Options{ExpectProcess: "populous"})
+	defer wrongProcess.Close()
+
+	result, err = wrongProcess.Check(context.Background(), hostPort)
+	assert.Equal(t, ClassWrongProcess, Classify(err), "Unexpected error class for %v", err)
+	assert.Zero(t, result.Attempts[0].Timing.Call, "Health check should not be made for the wrong process")
+
+	_, err = wrongProcess.Ping(context.Background(), hostPort)
+	assert.Equal(t, ClassWrongProcess, Classify(err), "Unexpected error class for %v", err)
+}
+
+// callHeaders are the transport and application headers of a call.
+type callHeaders struct {
+	caller          string
+	shardKey        string
+	routingKey      string
+	routingDelegate string
+	region          string
+}
+
+func newCallHeaders(ctx context.Context, headers map[string]string) callHeaders {
+	call := tchannel.CurrentCall(ctx)
+	return callHeaders{
+		caller:          call.CallerName(),
+		shardKey:        call.ShardKey(),
+		routingKey:      call.RoutingKey(),
+		routingDelegate: call.RoutingDelegate(),
+		region:          headers["region"],
+	}
+}
+
+// headersHandler records the headers of each health check.
+type headersHandler struct {
+	meta.TChanMeta
+	calls chan callHeaders
+}
+
+func (h headersHandler) Health(ctx thrift.Context, hr *meta.HealthRequest) (*meta.HealthStatus, error) {
+	h.calls <- newCallHeaders(ctx, ctx.Headers())
+	return &meta.HealthStatus{Ok: true}, nil
+}
+
+func TestCheckHeaders(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+
+	calls := make(chan callHeaders, 1)
+	thrift.NewServer(server).Register(meta.NewTChanMetaServer(headersHandler{calls: calls}))
+	require.NoError(t, json.Register(server, json.Handlers{
+		"health": func(ctx json.Context, req *jsonHealthRequest) (*jsonHealthStatus, error) {
+			calls <- newCallHeaders(ctx, ctx.Headers())
+			ok := true
+			return &jsonHealthStatus{OK: &ok}, nil
+		},
+	}, nil), "Failed to register JSON handler")
+
+	want := callHeaders{
+		caller:          "health-tester",
+		shardKey:        "shard",
+		routingKey:      "canary",
+		routingDelegate: "relay",
+		region:          "dca",
+	}
+	opts := Options{
+		Caller:          "health-tester",
+		ShardKey:        "shard",
+		RoutingKey:      "canary",
+		RoutingDelegate: "relay",
+		Headers:         map[string]string{"region": "dca"},
+	}
+
+	checker := newTestChecker(t, opts)
+	defer checker.Close()
+
+	_, err := checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "Thrift health check failed")
+	assert.Equal(t, want, <-calls, "Unexpected headers for Thrift health check")
+
+	opts.Format, opts.Endpoint = tchannel.JSON, "health"
+	checker = newTestChecker(t, opts)
+	defer checker.Close()
+
+	_, err = checker.Check(context.Background(), server.PeerInfo().HostPort)
+	require.NoError(t, err, "JSON health check failed")
+	assert.Equal(t, want, <-calls, "Unexpected headers for JSON health check")
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+// ErrorClass is the class of failure for a health check call, so that a
+// process that's down can be told apart from one that's missing a handler
+// or overloaded.
+type ErrorClass string
+
+// Error classes for failed health check calls.
+const (
+	ClassUnknown           ErrorClass = "error"
+	ClassConnectionRefused ErrorClass = "connection-refused"
+	ClassDNS               ErrorClass = "dns"
+	ClassTimeout           ErrorClass = "timeout"
+	ClassBusy              ErrorClass = "busy"
+	ClassDeclined          ErrorClass = "declined"
+	ClassBadRequest        ErrorClass = "bad-request"
+	ClassNetwork           ErrorClass = "network"
+	ClassProtocol          ErrorClass = "protocol"
+	ClassSlow              ErrorClass = "slow"
+	ClassWrongProcess      ErrorClass = "wrong-process"
+)
+
+// ErrorClasses are all the error classes, in a stable order.
+var ErrorClasses = []ErrorClass{
+	ClassUnknown,
+	ClassConnectionRefused,
+	ClassDNS,
+	ClassTimeout,
+	ClassBusy,
+	ClassDeclined,
+	ClassBadRequest,
+	ClassNetwork,
+	ClassProtocol,
+	ClassSlow,
+	ClassWrongProcess,
+}
+
+// ParseErrorClass returns the ErrorClass with the given name.
+func ParseErrorClass(s string) (ErrorClass, error) {
+	for _, class := range ErrorClasses {
+		if string(class) == s {
+			return class, nil
+		}
+	}
+
+	names := make([]string, len(ErrorClasses))
+	for i, class := range ErrorClasses {
+		names[i] = string(class)
+	}
+	return "", fmt.Errorf("unknown error class %q, must be one of %v", s, strings.Join(names, ", "))
+}
+
+// Classify returns the class of an error returned by a call, based on the
+// TChannel error code, or the network error if the call couldn't connect to
+// the peer.
+func Classify(err error) ErrorClass {
+	switch e := err.(type) {
+	case *CallError:
+		return e.Class
+	case *SlowError:
+		return ClassSlow
+	case *ProcessMismatchError:
+		return ClassWrongProcess
+	}
+
+	if _, ok := err.(tchannel.SystemError); ok {
+		switch tchannel.GetSystemErrorCode(err) {
+		case tchannel.ErrCodeTimeout:
+			return ClassTimeout
+		case tchannel.ErrCodeBusy:
+			return ClassBusy
+		case tchannel.ErrCodeDeclined:
+			return ClassDeclined
+		case tchannel.ErrCodeBadRequest:
+			return ClassBadRequest
+		case tchannel.ErrCodeNetwork:
+			return ClassNetwork
+		case tchannel.ErrCodeProtocol:
+			return ClassProtocol
+		}
+		return ClassUnknown
+	}
+
+	if err == context.DeadlineExceeded {
+		return ClassTimeout
+	}
+
+	// Errors connecting to the peer are returned by the dialer unwrapped.
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return ClassUnknown
+	}
+	if opErr.Timeout() {
+		return ClassTimeout
+	}
+	switch e := opErr.Err.(type) {
+	case *net.DNSError:
+		return ClassDNS
+	case *os.SyscallError:
+		if e.Err == syscall.ECONNREFUSED {
+			return ClassConnectionRefused
+		}
+	}
+	return ClassNetwork
+}
+
+// CallError is returned by Check when the health check call fails.
+type CallError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *CallError) Error() string {
+	return e.Err.Error()
+}
+
+// UnhealthyError is returned by Check when the peer responds to the health
+// check, but reports that it's not healthy.
+type UnhealthyError struct {
+	Message string
+}
+
+func (e *UnhealthyError) Error() string {
+	return fmt.Sprintf("not healthy: %v", e.Message)
+}
+
+// SlowError is the error for a health check where the peer reported itself
+// healthy, but took longer than the max latency to respond.
+type SlowError struct {
+	Latency    time.Duration
+	MaxLatency time.Duration
+}
+
+func (e *SlowError) Error() string {
+	return fmt.Sprintf("healthy, but took %v, more than the max latency of %v", e.Latency, e.MaxLatency)
+}
+
+// ProcessMismatchError is the error for a health check where the peer's
+// process name isn't the expected process, such as when its port has been
+// reused by another service.
+type ProcessMismatchError struct {
+	Expected    string
+	ProcessName string
+}
+
+func (e *ProcessMismatchError) Error() string {
+	return fmt.Sprintf("expected process %q, but peer is %q", e.Expected, e.ProcessName)
+}
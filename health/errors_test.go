@@ -0,0 +1,92 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{tchannel.ErrTimeout, ClassTimeout},
+		{context.DeadlineExceeded, ClassTimeout},
+		{tchannel.ErrServerBusy, ClassBusy},
+		{tchannel.NewSystemError(tchannel.ErrCodeDeclined, "declined"), ClassDeclined},
+		{tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "no handler"), ClassBadRequest},
+		{tchannel.NewSystemError(tchannel.ErrCodeNetwork, "reset"), ClassNetwork},
+		{tchannel.NewSystemError(tchannel.ErrCodeProtocol, "bad frame"), ClassProtocol},
+		{tchannel.NewSystemError(tchannel.ErrCodeUnexpected, "panic"), ClassUnknown},
+		{
+			err:  &net.OpError{Op: "dial", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}},
+			want: ClassConnectionRefused,
+		},
+		{
+			err:  &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "unknown.invalid"}},
+			want: ClassDNS,
+		},
+		{
+			err:  &net.OpError{Op: "read", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}},
+			want: ClassNetwork,
+		},
+		{&CallError{Class: ClassBusy, Err: errors.New("busy")}, ClassBusy},
+		{&SlowError{Latency: time.Second, MaxLatency: time.Millisecond}, ClassSlow},
+		{&ProcessMismatchError{Expected: "keyvalue", ProcessName: "populous[1]"}, ClassWrongProcess},
+		{errors.New("unknown"), ClassUnknown},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Classify(tt.err), "Unexpected class for %v", tt.err)
+	}
+}
+
+func TestParseErrorClass(t *testing.T) {
+	for _, class := range ErrorClasses {
+		got, err := ParseErrorClass(string(class))
+		require.NoError(t, err, "Failed to parse %v", class)
+		assert.Equal(t, class, got, "Unexpected class")
+	}
+
+	_, err := ParseErrorClass("unhealthy")
+	assert.Error(t, err, "Explicitly unhealthy is not an error class")
+}
+
+func TestErrorMessages(t *testing.T) {
+	err := &CallError{Class: ClassTimeout, Err: tchannel.ErrTimeout}
+	assert.Equal(t, tchannel.ErrTimeout.Error(), err.Error(), "CallError should use the call's error")
+	assert.Equal(t, "not healthy: draining", (&UnhealthyError{Message: "draining"}).Error())
+	assert.Equal(t, "healthy, but took 900ms, more than the max latency of 500ms",
+		(&SlowError{Latency: 900 * time.Millisecond, MaxLatency: 500 * time.Millisecond}).Error())
+	assert.Equal(t, `expected process "keyvalue", but peer is "populous[1]"`,
+		(&ProcessMismatchError{Expected: "keyvalue", ProcessName: "populous[1]"}).Error())
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"strings"
+
+	"github.com/uber/tchannel-go"
+)
+
+// Identity is how a peer identified itself in the TChannel init handshake.
+type Identity struct {
+	// HostPort is the host:port the peer advertised, or the address it was
+	// connected to if it only makes outbound connections.
+	HostPort    string
+	ProcessName string
+
+	Language        string
+	LanguageVersion string
+	TChannelVersion string
+}
+
+func identityFromPeerInfo(p tchannel.PeerInfo) Identity {
+	return Identity{
+		HostPort:        p.HostPort,
+		ProcessName:     p.ProcessName,
+		Language:        p.Version.Language,
+		LanguageVersion: p.Version.LanguageVersion,
+		TChannelVersion: p.Version.TChannelVersion,
+	}
+}
+
+// identityFromHeaders returns the identity from the headers of an init
+// message received on a connection to addr.
+func identityFromHeaders(headers map[string]string, addr string) Identity {
+	id := Identity{
+		HostPort:        headers[tchannel.InitParamHostPort],
+		ProcessName:     headers[tchannel.InitParamProcessName],
+		Language:        headers[tchannel.InitParamTChannelLanguage],
+		LanguageVersion: headers[tchannel.InitParamTChannelLanguageVersion],
+		TChannelVersion: headers[tchannel.InitParamTChannelVersion],
+	}
+	if id.HostPort == "0.0.0.0:0" {
+		id.HostPort = addr
+	}
+	return id
+}
+
+// checkProcess returns a *ProcessMismatchError if the peer's process name
+// isn't expected. Process names are compared ignoring the "[pid]" suffix
+// that TChannel libraries add, so "keyvalue" matches "keyvalue[1234]".
+func checkProcess(id Identity, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	name := id.ProcessName
+	if i := strings.LastIndex(name, "["); i >= 0 && strings.HasSuffix(name, "]") && name != expected {
+		name = name[:i]
+	}
+	if name != expected {
+		return &ProcessMismatchError{Expected: expected, ProcessName: id.ProcessName}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go"
+)
+
+func TestCheckProcess(t *testing.T) {
+	tests := []struct {
+		processName string
+		expected    string
+		wantErr     bool
+	}{
+		{"keyvalue[1234]", "", false},
+		{"keyvalue[1234]", "keyvalue", false},
+		{"keyvalue[1234]", "keyvalue[1234]", false},
+		{"keyvalue", "keyvalue", false},
+		{"keyvalue[1234]", "keyvalue[4321]", true},
+		{"populous[1234]", "keyvalue", true},
+		{"keyvalue-canary[1234]", "keyvalue", true},
+		{"", "keyvalue", true},
+	}
+
+	for _, tt := range tests {
+		err := checkProcess(Identity{ProcessName: tt.processName}, tt.expected)
+		if tt.wantErr {
+			assert.IsType(t, &ProcessMismatchError{}, err, "Expected %q not to match %q", tt.processName, tt.expected)
+		} else {
+			assert.NoError(t, err, "Expected %q to match %q", tt.processName, tt.expected)
+		}
+	}
+}
+
+func TestIdentityFromHeaders(t *testing.T) {
+	headers := map[string]string{
+		tchannel.InitParamHostPort:                "10.0.0.1:4532",
+		tchannel.InitParamProcessName:             "keyvalue[1234]",
+		tchannel.InitParamTChannelLanguage:        "node",
+		tchannel.InitParamTChannelLanguageVersion: "4.4.7",
+		tchannel.InitParamTChannelVersion:         "3.9.0",
+	}
+	assert.Equal(t, Identity{
+		HostPort:        "10.0.0.1:4532",
+		ProcessName:     "keyvalue[1234]",
+		Language:        "node",
+		LanguageVersion: "4.4.7",
+		TChannelVersion: "3.9.0",
+	}, identityFromHeaders(headers, "127.0.0.1:4532"))
+
+	headers[tchannel.InitParamHostPort] = "0.0.0.0:0"
+	assert.Equal(t, "127.0.0.1:4532", identityFromHeaders(headers, "127.0.0.1:4532").HostPort,
+		"Ephemeral peers should use the connection's address")
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+// TChannel frame types used by a probe.
+const (
+	_frameHeaderSize = 16
+
+	_frameInitReq byte = 0x01
+	_frameInitRes byte = 0x02
+	_framePingReq byte = 0xd0
+	_framePingRes byte = 0xd1
+	_frameError   byte = 0xff
+)
+
+// probeConn is a raw TChannel connection, used to measure how long it takes
+// to connect to a peer, since a channel's connections can't be timed by
+// phase, and to ping peers, which channels don't expose.
+type probeConn struct {
+	conn   net.Conn
+	nextID uint32
+}
+
+// dialProbe resolves and connects to hostPort, recording the time taken in
+// t.DNS and t.Connect. The connection's deadline is set from ctx.
+func dialProbe(ctx context.Context, hostPort string, t *Timing) (*probeConn, error) {
+	addr, err := resolve(ctx, hostPort, t)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	started := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	t.Connect = time.Since(started)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return &probeConn{conn: conn}, nil
+}
+
+// resolve returns hostPort with the host resolved to an IP address, recording
+// the time taken in t.DNS. Failures are returned as a dial would return them.
+func resolve(ctx context.Context, hostPort string, t *Timing) (string, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || net.ParseIP(host) != nil {
+		return hostPort, nil
+	}
+
+	started := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	t.DNS = time.Since(started)
+	if err != nil {
+		return "", &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+	return net.JoinHostPort(addrs[0], port), nil
+}
+
+func (c *probeConn) Close() error {
+	return c.conn.Close()
+}
+
+// handshake exchanges init messages with the peer, identifying as
+// processName, recording the time taken in t.Handshake, and returns the
+// peer's init headers.
+func (c *probeConn) handshake(processName string, t *Timing) (map[string]string, error) {
+	started := time.Now()
+	defer func() { t.Handshake = time.Since(started) }()
+
+	headers := map[string]string{
+		tchannel.InitParamHostPort:                "0.0.0.0:0",
+		tchannel.InitParamProcessName:             processName,
+		tchannel.InitParamTChannelLanguage:        "go",
+		tchannel.InitParamTChannelLanguageVersion: strings.TrimPrefix(runtime.Version(), "go"),
+		tchannel.InitParamTChannelVersion:         tchannel.VersionInfo,
+	}
+	if err := c.write(_frameInitReq, encodeInit(headers)); err != nil {
+		return nil, err
+	}
+
+	payload, err := c.read(_frameInitRes)
+	if err != nil {
+		return nil, err
+	}
+	return decodeInit(payload)
+}
+
+// ping sends a ping frame and waits for the response, recording the round
+// trip time in t.Ping.
+func (c *probeConn) ping(t *Timing) error {
+	started := time.Now()
+	defer func() { t.Ping = time.Since(started) }()
+
+	if err := c.write(_framePingReq, nil); err != nil {
+		return err
+	}
+	_, err := c.read(_framePingRes)
+	return err
+}
+
+// write sends a frame with the next message ID.
+func (c *probeConn) write(frameType byte, payload []byte) error {
+	c.nextID++
+	frame := make([]byte, _frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint16(frame[0:], uint16(len(frame)))
+	frame[2] = frameType
+	binary.BigEndian.PutUint32(frame[4:], c.nextID)
+	copy(frame[_frameHeaderSize:], payload)
+
+	_, err := c.conn.Write(frame)
+	return probeError(err)
+}
+
+// read reads a frame, and returns its payload if it's the expected type, or
+// the peer's error if it's an error frame.
+func (c *probeConn) read(frameType byte) ([]byte, error) {
+	var header [_frameHeaderSize]byte
+	if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+		return nil, probeError(err)
+	}
+	size := int(binary.BigEndian.Uint16(header[0:]))
+	if size < _frameHeaderSize {
+		return nil, tchannel.NewSystemError(tchannel.ErrCodeProtocol, "invalid frame size %v", size)
+	}
+
+	payload := make([]byte, size-_frameHeaderSize)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return nil, probeError(err)
+	}
+
+	switch header[2] {
+	case frameType:
+		return payload, nil
+	case _frameError:
+		return nil, decodeError(payload)
+	}
+	return nil, tchannel.NewSystemError(tchannel.ErrCodeProtocol, "expected frame type %#x, got %#x", frameType, header[2])
+}
+
+// probeError converts the peer closing the connection to a TChannel network
+// error. Other errors, such as timeouts, are returned as is.
+func probeError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return tchannel.NewSystemError(tchannel.ErrCodeNetwork, "connection closed by peer")
+	}
+	return err
+}
+
+// encodeInit returns the payload of an init message with the headers.
+func encodeInit(headers map[string]string) []byte {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint16(payload[0:], tchannel.CurrentProtocolVersion)
+	binary.BigEndian.PutUint16(payload[2:], uint16(len(headers)))
+	for k, v := range headers {
+		payload = appendString16(payload, k)
+		payload = appendString16(payload, v)
+	}
+	return payload
+}
+
+// decodeInit returns the headers from the payload of an init message.
+func decodeInit(payload []byte) (map[string]string, error) {
+	r := &payloadReader{b: payload}
+	version := r.uint16()
+	n := int(r.uint16())
+	headers := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k := r.string16()
+		headers[k] = r.string16()
+	}
+
+	if r.err != nil {
+		return nil, tchannel.NewSystemError(tchannel.ErrCodeProtocol, "invalid init message: %v", r.err)
+	}
+	if version != tchannel.CurrentProtocolVersion {
+		return nil, tchannel.NewSystemError(tchannel.ErrCodeProtocol, "unsupported protocol version %v", version)
+	}
+	return headers, nil
+}
+
+// decodeError returns the SystemError sent by the peer in an error frame.
+func decodeError(payload []byte) error {
+	r := &payloadReader{b: payload}
+	code := r.byte()
+	r.skip(25) // tracing
+	msg := r.string16()
+	if r.err != nil {
+		return tchannel.NewSystemError(tchannel.ErrCodeProtocol, "invalid error message: %v", r.err)
+	}
+	return tchannel.NewSystemError(tchannel.SystemErrCode(code), "%v", msg)
+}
+
+func appendString16(b []byte, s string) []byte {
+	var n [2]byte
+	binary.BigEndian.PutUint16(n[:], uint16(len(s)))
+	return append(append(b, n[:]...), s...)
+}
+
+// payloadReader reads fields from a frame payload, recording the first error.
+type payloadReader struct {
+	b   []byte
+	err error
+}
+
+func (r *payloadReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *payloadReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *payloadReader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *payloadReader) string16() string {
+	return string(r.next(int(r.uint16())))
+}
+
+func (r *payloadReader) skip(n int) {
+	r.next(n)
+}
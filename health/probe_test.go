@@ -0,0 +1,155 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+// serveFrames accepts a single connection, reads a frame, and replies with
+// the frame returned by respond.
+func serveFrames(t *testing.T, respond func(p *probeConn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		p := &probeConn{conn: conn}
+		if _, err := p.read(_frameInitReq); err != nil {
+			return
+		}
+		respond(p)
+	}()
+	return ln.Addr().String()
+}
+
+func probeHandshake(hostPort string) (map[string]string, Timing, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var timing Timing
+	p, err := dialProbe(ctx, hostPort, &timing)
+	if err != nil {
+		return nil, timing, err
+	}
+	defer p.Close()
+
+	headers, err := p.handshake("tcheck[1]", &timing)
+	return headers, timing, err
+}
+
+func TestProbeHandshake(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+
+	headers, timing, err := probeHandshake(server.PeerInfo().HostPort)
+	require.NoError(t, err, "Handshake failed")
+	assert.Equal(t, server.PeerInfo().HostPort, headers[tchannel.InitParamHostPort], "Unexpected host:port")
+	assert.Equal(t, server.PeerInfo().ProcessName, headers[tchannel.InitParamProcessName], "Unexpected process name")
+	assert.Equal(t, tchannel.VersionInfo, headers[tchannel.InitParamTChannelVersion], "Unexpected TChannel version")
+	assert.True(t, timing.Connect > 0, "Connect should be measured")
+	assert.True(t, timing.Handshake > 0, "Handshake should be measured")
+}
+
+func TestProbeHandshakeErrors(t *testing.T) {
+	tests := []struct {
+		msg     string
+		respond func(p *probeConn)
+		want    ErrorClass
+	}{
+		{
+			msg:     "closed",
+			respond: func(p *probeConn) {},
+			want:    ClassNetwork,
+		},
+		{
+			msg: "error frame",
+			respond: func(p *probeConn) {
+				payload := []byte{byte(tchannel.ErrCodeBusy)}
+				payload = append(payload, make([]byte, 25)...)
+				p.write(_frameError, appendString16(payload, "busy"))
+			},
+			want: ClassBusy,
+		},
+		{
+			msg: "unexpected frame",
+			respond: func(p *probeConn) {
+				p.write(_frameInitReq, encodeInit(nil))
+			},
+			want: ClassProtocol,
+		},
+		{
+			msg: "bad version",
+			respond: func(p *probeConn) {
+				p.write(_frameInitRes, []byte{0, 1, 0, 0})
+			},
+			want: ClassProtocol,
+		},
+		{
+			msg: "truncated headers",
+			respond: func(p *probeConn) {
+				p.write(_frameInitRes, []byte{0, 2, 0, 1, 0, 5})
+			},
+			want: ClassProtocol,
+		},
+		{
+			msg: "no response",
+			respond: func(p *probeConn) {
+				time.Sleep(2 * time.Second)
+			},
+			want: ClassTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		_, _, err := probeHandshake(serveFrames(t, tt.respond))
+		assert.Equal(t, tt.want, Classify(err), "%v: unexpected error class for %v", tt.msg, err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var timing Timing
+	addr, err := resolve(context.Background(), "127.0.0.1:4532", &timing)
+	require.NoError(t, err, "Resolve failed")
+	assert.Equal(t, "127.0.0.1:4532", addr, "IP addresses should not be resolved")
+	assert.Zero(t, timing.DNS, "IP addresses should not be resolved")
+
+	addr, err = resolve(context.Background(), "localhost:4532", &timing)
+	require.NoError(t, err, "Resolve failed")
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err, "Invalid address %v", addr)
+	assert.NotNil(t, net.ParseIP(host), "Expected an IP address, got %v", host)
+	assert.Equal(t, "4532", port, "Unexpected port")
+	assert.True(t, timing.DNS > 0, "DNS should be measured")
+}
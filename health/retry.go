@@ -0,0 +1,99 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultRetryOn are the error classes that are commonly transient.
+var DefaultRetryOn = []ErrorClass{ClassTimeout, ClassBusy, ClassDeclined, ClassNetwork}
+
+// RetryPolicy controls how failed health checks are retried. The zero value
+// never retries.
+type RetryPolicy struct {
+	// Retries is the number of times to retry after the first attempt.
+	Retries int
+
+	// Backoff is the delay before the first retry, which doubles after
+	// each retry.
+	Backoff time.Duration
+
+	// On are the classes of errors that are retried.
+	On []ErrorClass
+}
+
+func (p RetryPolicy) validate() error {
+	if p.Retries < 0 {
+		return fmt.Errorf("retries must not be negative, got %v", p.Retries)
+	}
+	if p.Backoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative, got %v", p.Backoff)
+	}
+	return nil
+}
+
+// shouldRetry returns whether an attempt that returned err should be retried.
+func (p RetryPolicy) shouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+	class := Classify(err)
+	for _, c := range p.On {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// retry calls attempt until it succeeds, returns an error that shouldn't be
+// retried, the retries are exhausted, or ctx is done.
+func (p RetryPolicy) retry(ctx context.Context, attempt func(*Attempt) error) []Attempt {
+	backoff := p.Backoff
+
+	var attempts []Attempt
+	for {
+		var a Attempt
+		started := time.Now()
+		err := attempt(&a)
+		a.Err, a.Latency = err, time.Since(started)
+		attempts = append(attempts, a)
+
+		if len(attempts) > p.Retries || !p.shouldRetry(err) {
+			return attempts
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(time.Now()) <= backoff {
+			// There's no time left for another attempt after backing off.
+			return attempts
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempts
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	busy := tchannel.ErrServerBusy
+	badRequest := tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "no handler")
+	onBusy := []ErrorClass{ClassBusy}
+
+	tests := []struct {
+		msg          string
+		policy       RetryPolicy
+		deadline     time.Duration
+		errs         []error
+		wantAttempts int
+	}{
+		{
+			msg:          "no retries",
+			policy:       RetryPolicy{On: onBusy},
+			errs:         []error{busy, nil},
+			wantAttempts: 1,
+		},
+		{
+			msg:          "success after retries",
+			policy:       RetryPolicy{Retries: 3, On: onBusy},
+			errs:         []error{busy, busy, nil},
+			wantAttempts: 3,
+		},
+		{
+			msg:          "retries exhausted",
+			policy:       RetryPolicy{Retries: 2, On: onBusy},
+			errs:         []error{busy, busy, busy, nil},
+			wantAttempts: 3,
+		},
+		{
+			msg:          "error not retried",
+			policy:       RetryPolicy{Retries: 2, On: onBusy},
+			errs:         []error{badRequest, nil},
+			wantAttempts: 1,
+		},
+		{
+			msg:          "deadline shorter than backoff",
+			policy:       RetryPolicy{Retries: 2, Backoff: time.Second, On: onBusy},
+			deadline:     10 * time.Millisecond,
+			errs:         []error{busy, nil},
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.deadline > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+			defer cancel()
+		}
+
+		var i int
+		attempts := tt.policy.retry(ctx, func(*Attempt) error {
+			err := tt.errs[i]
+			i++
+			return err
+		})
+		assert.Len(t, attempts, tt.wantAttempts, "%v: unexpected number of attempts", tt.msg)
+		assert.Equal(t, tt.errs[tt.wantAttempts-1], attempts[len(attempts)-1].Err, "%v: unexpected last error", tt.msg)
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	assert.NoError(t, RetryPolicy{}.validate(), "Zero policy should be valid")
+	assert.Error(t, RetryPolicy{Retries: -1}.validate(), "Negative retries should be invalid")
+	assert.Error(t, RetryPolicy{Backoff: -1}.validate(), "Negative backoff should be invalid")
+}
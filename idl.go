@@ -0,0 +1,320 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+// idlResult is the result of Meta::thriftIDL. Our Meta IDL returns the IDL as
+// a single string, but newer versions return ThriftIDLs with multiple files,
+// so the result is decoded based on the type of the field sent by the peer.
+type idlResult struct {
+	idl  *string
+	idls *meta.ThriftIDLs
+}
+
+func (r *idlResult) Read(iprot athrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		_, fieldType, fieldID, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if fieldType == athrift.STOP {
+			break
+		}
+
+		switch {
+		case fieldID == 0 && fieldType == athrift.STRING:
+			v, err := iprot.ReadString()
+			if err != nil {
+				return err
+			}
+			r.idl = &v
+		case fieldID == 0 && fieldType == athrift.STRUCT:
+			r.idls = meta.NewThriftIDLs()
+			if err := r.idls.Read(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldType); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	return iprot.ReadStructEnd()
+}
+
+func (r *idlResult) Write(oprot athrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("thriftIDL_result"); err != nil {
+		return err
+	}
+	switch {
+	case r.idls != nil:
+		if err := oprot.WriteFieldBegin("success", athrift.STRUCT, 0); err != nil {
+			return err
+		}
+		if err := r.idls.Write(oprot); err != nil {
+			return err
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return err
+		}
+	case r.idl != nil:
+		if err := oprot.WriteFieldBegin("success", athrift.STRING, 0); err != nil {
+			return err
+		}
+		if err := oprot.WriteString(*r.idl); err != nil {
+			return err
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return err
+	}
+	return oprot.WriteStructEnd()
+}
+
+// thriftIDLs returns the result as ThriftIDLs. A single IDL is named after
+// the service, since peers returning a string don't specify a filename.
+func (r *idlResult) thriftIDLs(serviceName string) *meta.ThriftIDLs {
+	if r.idls != nil || r.idl == nil {
+		return r.idls
+	}
+	filename := meta.Filename(serviceName + ".thrift")
+	return &meta.ThriftIDLs{
+		Idls:       map[meta.Filename]string{filename: *r.idl},
+		EntryPoint: filename,
+	}
+}
+
+// callThriftIDL calls Meta::thriftIDL using the given client.
+func callThriftIDL(thriftClient thrift.TChanClient, opts checkOptions) (*meta.ThriftIDLs, error) {
+	ctx, cancel := newContext(opts)
+	defer cancel()
+
+	var resp idlResult
+	success, err := thriftClient.Call(ctx, "Meta", "thriftIDL", &meta.MetaThriftIDLArgs{}, &resp)
+	if err == nil && !success {
+		err = fmt.Errorf("received no result or unknown exception for thriftIDL")
+	}
+	if err == nil && resp.idl == nil && resp.idls == nil {
+		err = fmt.Errorf("received no IDL for thriftIDL")
+	}
+	return resp.thriftIDLs(opts.serviceName), err
+}
+
+// idlFilenames returns the filenames in idls with the entry point first,
+// followed by the remaining files in sorted order.
+func idlFilenames(idls *meta.ThriftIDLs) []string {
+	var names []string
+	for name := range idls.Idls {
+		if name != idls.EntryPoint {
+			names = append(names, string(name))
+		}
+	}
+	sort.Strings(names)
+
+	if _, ok := idls.Idls[idls.EntryPoint]; ok {
+		names = append([]string{string(idls.EntryPoint)}, names...)
+	}
+	return names
+}
+
+// idlFetch is the result of fetching the IDL from a peer.
+type idlFetch struct {
+	peer         string
+	resolvedPeer string
+	relay        string
+	serviceName  string
+	idls         *meta.ThriftIDLs
+
+	// files are the paths that the IDL was written to, if --outputDir is set.
+	files []string
+}
+
+func runIDL(p printer, opts checkOptions) {
+	if len(peers) > 1 {
+		err := exitError{_exitUsage, "Must specify a single peer to fetch the IDL from"}
+		p.printIDL(idlFetch{serviceName: opts.serviceName}, err)
+		exit(err)
+		return
+	}
+
+	var peer string
+	if len(peers) == 1 {
+		peer = peers[0]
+	}
+
+	result, err := fetchIDL(peer, *hostsFile, opts)
+	if err == nil && *outputDir != "" {
+		result.files, err = writeIDLs(*outputDir, result.idls)
+	}
+	p.printIDL(result, err)
+	exit(err)
+}
+
+// fetchIDL calls Meta::thriftIDL on the service at peer, or through the
+// Hyperbahn relays listed in hostsFile if peer is empty.
+func fetchIDL(peer, hostsFile string, opts checkOptions) (idlFetch, error) {
+	result := idlFetch{peer: peer, serviceName: opts.serviceName}
+	if peer == "" && hostsFile == "" {
+		return result, exitError{_exitUsage, "Must specify a peer or a Hyperbahn hosts file to fetch the IDL from"}
+	}
+	if err := validateArgs(opts); err != nil {
+		return result, err
+	}
+
+	var (
+		ch  *tchannel.Channel
+		err error
+	)
+	if peer != "" {
+		ch, err = newChannel(opts)
+	} else {
+		ch, err = newHyperbahnChannel(opts, hostsFile)
+	}
+	if err != nil {
+		return result, err
+	}
+	defer ch.Close()
+
+	var clientOpts *thrift.ClientOptions
+	if peer != "" {
+		result.resolvedPeer = health.RemapLocalhost(peer)
+		clientOpts = &thrift.ClientOptions{HostPort: result.resolvedPeer}
+	}
+
+	result.idls, err = callThriftIDL(thrift.NewClient(ch, opts.serviceName, clientOpts), opts)
+	if peer == "" {
+		result.relay = health.ConnectedPeer(ch)
+	}
+	if err != nil {
+		return result, exitError{_exitUnknownUnhealthy, fmt.Sprintf("Failed to get IDL for %v\nError: %v\n", opts.serviceName, err)}
+	}
+	return result, nil
+}
+
+// writeIDLs writes each file in idls to dir, and returns the paths written.
+// Filenames may contain directories for included IDLs, but must not refer to
+// paths outside of dir.
+func writeIDLs(dir string, idls *meta.ThriftIDLs) ([]string, error) {
+	var paths []string
+	for _, name := range idlFilenames(idls) {
+		cleaned := filepath.Clean(filepath.FromSlash(name))
+		if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+			return paths, fmt.Errorf("refusing to write IDL %q outside of %v", name, dir)
+		}
+
+		path := filepath.Join(dir, cleaned)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return paths, err
+		}
+		if err := ioutil.WriteFile(path, []byte(idls.Idls[meta.Filename(name)]), 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// jsonIDLResult is the record written for the idl command with --output json.
+type jsonIDLResult struct {
+	Peer         string            `json:"peer,omitempty"`
+	ResolvedPeer string            `json:"resolvedPeer,omitempty"`
+	Relay        string            `json:"relay,omitempty"`
+	Service      string            `json:"service"`
+	EntryPoint   string            `json:"entryPoint,omitempty"`
+	IDLs         map[string]string `json:"idls,omitempty"`
+	Files        []string          `json:"files,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// printIDL prints the fetched IDL, or the files it was written to.
+func (p printer) printIDL(r idlFetch, err error) {
+	if p.format == outputJSON {
+		jr := jsonIDLResult{
+			Peer:         r.peer,
+			ResolvedPeer: r.resolvedPeer,
+			Relay:        r.relay,
+			Service:      r.serviceName,
+			Files:        r.files,
+		}
+		if r.idls != nil {
+			jr.EntryPoint = string(r.idls.EntryPoint)
+			jr.IDLs = make(map[string]string, len(r.idls.Idls))
+			for name, contents := range r.idls.Idls {
+				jr.IDLs[string(name)] = contents
+			}
+		}
+		if err != nil {
+			jr.Error = err.Error()
+		}
+		p.writeJSON(jr)
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+		return
+	}
+	if len(r.files) > 0 {
+		for _, f := range r.files {
+			fmt.Fprintln(p.w, "Wrote", f)
+		}
+		return
+	}
+
+	names := idlFilenames(r.idls)
+	for i, name := range names {
+		if len(names) > 1 {
+			if i > 0 {
+				fmt.Fprintln(p.w)
+			}
+			fmt.Fprintf(p.w, "==> %v <==\n", name)
+		}
+		contents := r.idls.Idls[meta.Filename(name)]
+		fmt.Fprint(p.w, contents)
+		if !strings.HasSuffix(contents, "\n") {
+			fmt.Fprintln(p.w)
+		}
+	}
+}
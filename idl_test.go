@@ -0,0 +1,182 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+const testIDL = "service KeyValue {\n  string get(1: string key)\n}\n"
+
+type idlHandler struct {
+	meta.TChanMeta
+}
+
+func (idlHandler) ThriftIDL(_ thrift.Context) (string, error) {
+	return testIDL, nil
+}
+
+// multiIDLServer serves Meta::thriftIDL using the newer Meta IDL that
+// returns ThriftIDLs.
+type multiIDLServer struct {
+	idls *meta.ThriftIDLs
+}
+
+func (multiIDLServer) Service() string { return "Meta" }
+
+func (multiIDLServer) Methods() []string { return []string{"thriftIDL"} }
+
+func (s multiIDLServer) Handle(_ thrift.Context, _ string, protocol athrift.TProtocol) (bool, athrift.TStruct, error) {
+	var args meta.MetaThriftIDLArgs
+	if err := args.Read(protocol); err != nil {
+		return false, nil, err
+	}
+	return true, &idlResult{idls: s.idls}, nil
+}
+
+func setupIDLServer(t *testing.T, server thrift.TChanServer) *tchannel.Channel {
+	ch := setupServer(t, nil)
+	thrift.NewServer(ch).Register(server)
+	return ch
+}
+
+var testThriftIDLs = &meta.ThriftIDLs{
+	Idls: map[meta.Filename]string{
+		"kv.thrift":          "include \"shared/base.thrift\"\n" + testIDL,
+		"shared/base.thrift": "struct Base {}\n",
+	},
+	EntryPoint: "kv.thrift",
+}
+
+func TestFetchIDL(t *testing.T) {
+	single := setupIDLServer(t, meta.NewTChanMetaServer(idlHandler{}))
+	defer single.Close()
+
+	multi := setupIDLServer(t, multiIDLServer{testThriftIDLs})
+	defer multi.Close()
+
+	noHandler := setupServer(t, nil)
+	defer noHandler.Close()
+
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+
+	result, err := fetchIDL(single.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Failed to fetch single IDL")
+	assert.Equal(t, meta.Filename("svc.thrift"), result.idls.EntryPoint, "Unexpected entry point")
+	assert.Equal(t, testIDL, result.idls.Idls["svc.thrift"], "Unexpected IDL")
+
+	result, err = fetchIDL(multi.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Failed to fetch multiple IDLs")
+	assert.Equal(t, testThriftIDLs, result.idls, "Unexpected IDLs")
+
+	_, err = fetchIDL(noHandler.PeerInfo().HostPort, "", opts)
+	require.Error(t, err, "Expected peer without a handler to fail")
+	assert.Equal(t, _exitUnknownUnhealthy, getExitCode(err), "Unexpected exit code")
+
+	_, err = fetchIDL("", "", opts)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing peer to fail")
+}
+
+func TestFetchIDLHyperbahn(t *testing.T) {
+	relay := setupIDLServer(t, meta.NewTChanMetaServer(idlHandler{}))
+	defer relay.Close()
+
+	hostsFile := writeHostsFile(t, relay.PeerInfo().HostPort)
+	defer os.Remove(hostsFile)
+
+	result, err := fetchIDL("", hostsFile, checkOptions{serviceName: "svc", timeout: time.Second})
+	require.NoError(t, err, "Failed to fetch IDL through Hyperbahn")
+	assert.Equal(t, relay.PeerInfo().HostPort, result.relay, "Unexpected relay")
+}
+
+func TestWriteIDLs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcheck-idl")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	paths, err := writeIDLs(dir, testThriftIDLs)
+	require.NoError(t, err, "Failed to write IDLs")
+	assert.Equal(t, []string{
+		filepath.Join(dir, "kv.thrift"),
+		filepath.Join(dir, "shared", "base.thrift"),
+	}, paths, "Unexpected paths")
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "shared", "base.thrift"))
+	require.NoError(t, err, "Failed to read included IDL")
+	assert.Equal(t, "struct Base {}\n", string(contents), "Unexpected included IDL")
+
+	for _, name := range []string{"../escape.thrift", "/etc/passwd"} {
+		_, err := writeIDLs(dir, &meta.ThriftIDLs{
+			Idls:       map[meta.Filename]string{meta.Filename(name): ""},
+			EntryPoint: meta.Filename(name),
+		})
+		assert.Error(t, err, "Expected %q to be rejected", name)
+	}
+}
+
+func TestPrintIDL(t *testing.T) {
+	var buf bytes.Buffer
+	p := newPrinter(&buf, outputText)
+
+	p.printIDL(idlFetch{idls: testThriftIDLs}, nil)
+	assert.Equal(t, "==> kv.thrift <==\n"+testThriftIDLs.Idls["kv.thrift"]+
+		"\n==> shared/base.thrift <==\nstruct Base {}\n", buf.String())
+
+	buf.Reset()
+	p.printIDL(idlFetch{idls: &meta.ThriftIDLs{
+		Idls:       map[meta.Filename]string{"svc.thrift": "struct S {}"},
+		EntryPoint: "svc.thrift",
+	}}, nil)
+	assert.Equal(t, "struct S {}\n", buf.String())
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printIDL(idlFetch{serviceName: "svc", idls: testThriftIDLs}, nil)
+	assert.Contains(t, buf.String(), `"entryPoint":"kv.thrift"`, "Missing entry point")
+}
+
+func TestIntegrationIDL(t *testing.T) {
+	server := setupIDLServer(t, meta.NewTChanMetaServer(idlHandler{}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tcheck-idl")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	setArgs("idl", "--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName(), "--outputDir", dir)
+	main()
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, server.ServiceName()+".thrift"))
+	require.NoError(t, err, "Failed to read written IDL")
+	assert.Equal(t, testIDL, string(contents), "Unexpected IDL")
+}
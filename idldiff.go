@@ -0,0 +1,250 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
+	"github.com/uber/tcheck/internal/idl"
+
+	"github.com/uber/tchannel-go/thrift"
+)
+
+// idlDiffResult is the result of comparing the IDL served by a single peer
+// against the local IDL.
+type idlDiffResult struct {
+	peer         string
+	resolvedPeer string
+	relay        string
+	serviceName  string
+	changes      []idl.Change
+	err          error
+}
+
+// target returns a description of where the IDL was fetched from.
+func (r idlDiffResult) target() string {
+	if r.peer != "" {
+		return r.peer
+	}
+	return fmt.Sprintf("%v via %v", r.serviceName, r.relay)
+}
+
+// breaking returns the number of breaking changes.
+func (r idlDiffResult) breaking() int {
+	var n int
+	for _, c := range r.changes {
+		if c.Breaking {
+			n++
+		}
+	}
+	return n
+}
+
+func (r idlDiffResult) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("%v Error: %v", r.target(), r.err)
+	}
+	if len(r.changes) == 0 {
+		return fmt.Sprintf("%v no changes", r.target())
+	}
+
+	breaking := r.breaking()
+	s := fmt.Sprintf("%v %v breaking, %v compatible changes", r.target(), breaking, len(r.changes)-breaking)
+	for _, c := range r.changes {
+		label := "compatible"
+		if c.Breaking {
+			label = "BREAKING"
+		}
+		s += fmt.Sprintf("\n  %v %v", label, c)
+	}
+	return s
+}
+
+// jsonChange is the record written for each change with --output json.
+type jsonChange struct {
+	Breaking bool   `json:"breaking"`
+	Kind     string `json:"kind"`
+	Element  string `json:"element"`
+	Name     string `json:"name"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// jsonIDLDiffResult is the record written for each peer with --output json.
+type jsonIDLDiffResult struct {
+	Peer         string       `json:"peer,omitempty"`
+	ResolvedPeer string       `json:"resolvedPeer,omitempty"`
+	Relay        string       `json:"relay,omitempty"`
+	Service      string       `json:"service"`
+	Breaking     bool         `json:"breaking"`
+	Changes      []jsonChange `json:"changes"`
+	Error        string       `json:"error,omitempty"`
+}
+
+func newJSONIDLDiffResult(r idlDiffResult) jsonIDLDiffResult {
+	jr := jsonIDLDiffResult{
+		Peer:         r.peer,
+		ResolvedPeer: r.resolvedPeer,
+		Relay:        r.relay,
+		Service:      r.serviceName,
+		Breaking:     r.breaking() > 0,
+		Changes:      make([]jsonChange, 0, len(r.changes)),
+	}
+	for _, c := range r.changes {
+		jr.Changes = append(jr.Changes, jsonChange(c))
+	}
+	if r.err != nil {
+		jr.Error = r.err.Error()
+	}
+	return jr
+}
+
+func runIDLDiff(p printer, opts checkOptions) {
+	results, err := idlDiff(peers, *hostsFile, *idlFile, opts, *concurrency)
+	p.printIDLDiffs(results, err)
+	exit(err)
+}
+
+// idlDiff compares the IDL served by Meta::thriftIDL on the service at each
+// peer, or through the Hyperbahn relays listed in hostsFile if there are no
+// peers, against the local IDL at idlFile.
+func idlDiff(peers []string, hostsFile, idlFile string, opts checkOptions, concurrency int) ([]idlDiffResult, error) {
+	if idlFile == "" {
+		return nil, exitError{_exitUsage, "Must specify an IDL file to compare against"}
+	}
+	if len(peers) == 0 && hostsFile == "" {
+		return nil, exitError{_exitUsage, "Must specify a peer or a Hyperbahn hosts file to compare"}
+	}
+	if err := validateArgs(opts); err != nil {
+		return nil, err
+	}
+	if concurrency <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive concurrency"}
+	}
+
+	contract, err := idl.ParseFile(idlFile)
+	if err != nil {
+		return nil, exitError{_exitUsage, fmt.Sprintf("Failed to parse IDL file %v: %v", idlFile, err)}
+	}
+
+	if len(peers) == 0 {
+		ch, err := newHyperbahnChannel(opts, hostsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer ch.Close()
+
+		result := diffPeerIDL(thrift.NewClient(ch, opts.serviceName, nil), contract, opts)
+		result.relay = health.ConnectedPeer(ch)
+		return []idlDiffResult{result}, idlDiffErr([]idlDiffResult{result})
+	}
+
+	ch, err := newChannel(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	results := make([]idlDiffResult, len(peers))
+	forEachPeer(peers, concurrency, func(i int, peer string) {
+		resolvedPeer := health.RemapLocalhost(peer)
+		client := thrift.NewClient(ch, opts.serviceName, &thrift.ClientOptions{HostPort: resolvedPeer})
+		results[i] = diffPeerIDL(client, contract, opts)
+		results[i].peer = peer
+		results[i].resolvedPeer = resolvedPeer
+	})
+	return results, idlDiffErr(results)
+}
+
+// diffPeerIDL fetches the IDL using the given client, and compares it against
+// the contract.
+func diffPeerIDL(thriftClient thrift.TChanClient, contract *idl.Program, opts checkOptions) idlDiffResult {
+	result := idlDiffResult{serviceName: opts.serviceName}
+
+	idls, err := callThriftIDL(thriftClient, opts)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	served, err := parseThriftIDLs(idls)
+	if err != nil {
+		result.err = fmt.Errorf("failed to parse served IDL: %v", err)
+		return result
+	}
+
+	result.changes = idl.Diff(contract, served)
+	return result
+}
+
+// parseThriftIDLs parses the IDL files returned by Meta::thriftIDL.
+func parseThriftIDLs(idls *meta.ThriftIDLs) (*idl.Program, error) {
+	files := make(map[string]string, len(idls.Idls))
+	for name, contents := range idls.Idls {
+		files[string(name)] = contents
+	}
+	return idl.ParseFiles(files, string(idls.EntryPoint))
+}
+
+// idlDiffErr returns an exitError if any peer serves an IDL with breaking
+// changes, or the IDL could not be compared. The exit code only indicates
+// breaking changes if every failure was a breaking change.
+func idlDiffErr(results []idlDiffResult) error {
+	var breaking, failed int
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			failed++
+		case r.breaking() > 0:
+			breaking++
+		}
+	}
+
+	if failed > 0 {
+		return exitError{_exitUnknownUnhealthy, fmt.Sprintf("Failed to compare IDL from %v of %v peers", failed, len(results))}
+	}
+	if breaking > 0 {
+		return exitError{_exitIDLBreaking, fmt.Sprintf("Breaking IDL changes on %v of %v peers", breaking, len(results))}
+	}
+	return nil
+}
+
+// printIDLDiffs prints the changes in the IDL served by each peer, where err
+// is the error returned by idlDiff.
+func (p printer) printIDLDiffs(results []idlDiffResult, err error) {
+	if p.format == outputJSON {
+		for _, r := range results {
+			p.writeJSON(newJSONIDLDiffResult(r))
+		}
+		if len(results) == 0 && err != nil {
+			p.writeJSON(jsonIDLDiffResult{Error: err.Error()})
+		}
+		return
+	}
+
+	for _, r := range results {
+		fmt.Fprintln(p.w, r)
+	}
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+	}
+}
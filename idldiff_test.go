@@ -0,0 +1,160 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeIDLFile writes an IDL to a temp dir, and returns its path along with
+// a function to remove it.
+func writeIDLFile(t *testing.T, name, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "tcheck-idl")
+	require.NoError(t, err, "Failed to create temp dir")
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644), "Failed to write IDL")
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestIDLDiff(t *testing.T) {
+	single := setupIDLServer(t, meta.NewTChanMetaServer(idlHandler{}))
+	defer single.Close()
+
+	multi := setupIDLServer(t, multiIDLServer{testThriftIDLs})
+	defer multi.Close()
+
+	noHandler := setupServer(t, nil)
+	defer noHandler.Close()
+
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+
+	same, cleanup := writeIDLFile(t, "kv.thrift", testIDL)
+	defer cleanup()
+
+	results, err := idlDiff([]string{single.PeerInfo().HostPort}, "", same, opts, 1)
+	require.NoError(t, err, "Expected no changes")
+	require.Len(t, results, 1, "Unexpected number of results")
+	assert.Empty(t, results[0].changes, "Expected no changes")
+
+	// The served IDL from multi includes an additional struct.
+	results, err = idlDiff([]string{multi.PeerInfo().HostPort}, "", same, opts, 1)
+	require.NoError(t, err, "Expected compatible changes only")
+	assert.Equal(t, 1, len(results[0].changes), "Unexpected changes")
+	assert.Equal(t, 0, results[0].breaking(), "Unexpected breaking changes")
+
+	newer, cleanup := writeIDLFile(t, "kv.thrift", testIDL+"service Admin {}\n")
+	defer cleanup()
+
+	results, err = idlDiff([]string{single.PeerInfo().HostPort, multi.PeerInfo().HostPort}, "", newer, opts, 2)
+	require.Error(t, err, "Expected breaking changes")
+	assert.Equal(t, _exitIDLBreaking, getExitCode(err), "Unexpected exit code")
+	for _, r := range results {
+		assert.Equal(t, 1, r.breaking(), "Expected removed service for %v", r.peer)
+	}
+
+	results, err = idlDiff([]string{single.PeerInfo().HostPort, noHandler.PeerInfo().HostPort}, "", newer, opts, 2)
+	require.Error(t, err, "Expected failure for peer without a handler")
+	assert.Equal(t, _exitUnknownUnhealthy, getExitCode(err), "Errors should take precedence over breaking changes")
+	assert.Error(t, results[1].err, "Expected error for peer without a handler")
+}
+
+func TestIDLDiffUsage(t *testing.T) {
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+
+	invalid, cleanup := writeIDLFile(t, "bad.thrift", "service {")
+	defer cleanup()
+
+	tests := []struct {
+		msg     string
+		peers   []string
+		idlFile string
+	}{
+		{"missing IDL file", []string{"127.0.0.1:1"}, ""},
+		{"missing peers", nil, invalid},
+		{"unreadable IDL file", []string{"127.0.0.1:1"}, invalid + ".missing"},
+		{"invalid IDL file", []string{"127.0.0.1:1"}, invalid},
+	}
+
+	for _, tt := range tests {
+		_, err := idlDiff(tt.peers, "", tt.idlFile, opts, 1)
+		assert.Equal(t, _exitUsage, getExitCode(err), "%v: unexpected exit code", tt.msg)
+	}
+}
+
+func TestPrintIDLDiffs(t *testing.T) {
+	contract, cleanup := writeIDLFile(t, "kv.thrift", testIDL+"service Admin {}\n")
+	defer cleanup()
+
+	server := setupIDLServer(t, meta.NewTChanMetaServer(idlHandler{}))
+	defer server.Close()
+
+	results, err := idlDiff([]string{server.PeerInfo().HostPort}, "", contract, checkOptions{serviceName: "svc", timeout: time.Second}, 1)
+	require.Error(t, err, "Expected breaking changes")
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputText).printIDLDiffs(results, err)
+	assert.Equal(t, server.PeerInfo().HostPort+" 1 breaking, 0 compatible changes\n"+
+		"  BREAKING removed service Admin\n"+
+		"Breaking IDL changes on 1 of 1 peers\n", buf.String())
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printIDLDiffs(results, err)
+	assert.Contains(t, buf.String(), `"breaking":true,"changes":[{"breaking":true,"kind":"removed","element":"service","name":"Admin"}]`)
+}
+
+func TestIntegrationIDLDiff(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	server := setupIDLServer(t, meta.NewTChanMetaServer(idlHandler{}))
+	defer server.Close()
+
+	contract, cleanup := writeIDLFile(t, "kv.thrift", "service KeyValue {\n  string get(1: i64 key)\n}\n")
+	defer cleanup()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		setArgs("idl-diff", "--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName(), "--idlFile", contract)
+		main()
+	}()
+
+	<-done
+	assert.Equal(t, _exitIDLBreaking, exitCode, "Expected breaking exit code")
+}
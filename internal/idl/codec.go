@@ -0,0 +1,750 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+)
+
+// Method returns the service and function for a method name of the form
+// "Service::function". Functions inherited from extended services are
+// called using the name of the extending service.
+func (p *Program) Method(method string) (string, *Function, error) {
+	parts := strings.Split(method, "::")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", nil, fmt.Errorf("method %q must be of the form Service::function", method)
+	}
+
+	service, ok := p.Services[parts[0]]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown service %q", parts[0])
+	}
+	f, ok := p.functions(service)[parts[1]]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown function %q in service %v", parts[1], parts[0])
+	}
+	if f.Oneway {
+		return "", nil, fmt.Errorf("function %v is oneway, which TChannel does not support", method)
+	}
+	return parts[0], f, nil
+}
+
+// writeFunc writes a value that has been converted from JSON.
+type writeFunc func(athrift.TProtocol) error
+
+// Args are the arguments to a function, converted from JSON so they can be
+// written as the function's Thrift args struct.
+type Args struct {
+	name  string
+	write writeFunc
+}
+
+// NewArgs converts a JSON object of arguments for f, keyed by argument name.
+//
+// Values are converted based on the argument types: binary values are base64
+// strings, enums are the name or number of the value, and map keys that
+// aren't strings are written as their JSON, e.g. "1" or "true".
+func (p *Program) NewArgs(f *Function, data []byte) (*Args, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after the arguments")
+	}
+	if v == nil {
+		v = map[string]interface{}{}
+	}
+
+	write, err := p.encodeStruct("args", f.Args, v)
+	if err != nil {
+		return nil, err
+	}
+	return &Args{name: f.Name + "_args", write: write}, nil
+}
+
+// Write writes the args struct.
+func (a *Args) Write(oprot athrift.TProtocol) error {
+	if err := oprot.WriteStructBegin(a.name); err != nil {
+		return err
+	}
+	return a.write(oprot)
+}
+
+// Read is not supported, since Args are only used to make calls.
+func (a *Args) Read(iprot athrift.TProtocol) error {
+	return fmt.Errorf("reading %v is not supported", a.name)
+}
+
+// Exception is an exception declared by a function.
+type Exception struct {
+	// Field is the name of the exception in the function's throws clause.
+	Field string
+
+	// Type is the name of the exception type.
+	Type string
+
+	// Value is the decoded exception.
+	Value interface{}
+}
+
+func (e *Exception) String() string {
+	b, _ := json.Marshal(e.Value)
+	return fmt.Sprintf("%v (%v): %s", e.Type, e.Field, b)
+}
+
+// Result is the result of calling a function, decoded from the function's
+// Thrift result struct into values that can be marshalled as JSON.
+type Result struct {
+	p *Program
+	f *Function
+
+	// Success is the value returned by the function, which is nil if the
+	// function is void or threw an exception.
+	Success interface{}
+
+	// Exception is the declared exception thrown by the function, if any.
+	Exception *Exception
+}
+
+// NewResult returns a Result to read the result of calling f into.
+func (p *Program) NewResult(f *Function) *Result {
+	return &Result{p: p, f: f}
+}
+
+// Read reads the result struct. Fields that aren't part of the function
+// are skipped. The payload is read in full before it's decoded, so that the
+// sizes of lists and maps can be checked against the bytes that are left.
+func (r *Result) Read(iprot athrift.TProtocol) error {
+	payload, err := readPayload(iprot.Transport())
+	if err != nil {
+		return err
+	}
+	iprot = athrift.NewTBinaryProtocolTransport(payload)
+
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		_, wireType, id, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if wireType == athrift.STOP {
+			break
+		}
+
+		if err := r.readField(iprot, wireType, int(id)); err != nil {
+			return err
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	return iprot.ReadStructEnd()
+}
+
+// readPayload reads the rest of trans into a memory buffer.
+func readPayload(trans athrift.TTransport) (*athrift.TMemoryBuffer, error) {
+	buf := athrift.NewTMemoryBuffer()
+	chunk := make([]byte, 4096)
+	for {
+		n, err := trans.Read(chunk)
+		buf.Write(chunk[:n])
+		if err == io.EOF {
+			return buf, nil
+		}
+		if te, ok := err.(athrift.TTransportException); ok && te.TypeId() == athrift.END_OF_FILE {
+			return buf, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *Result) readField(iprot athrift.TProtocol, wireType athrift.TType, id int) error {
+	if id == 0 && r.f.Returns != nil {
+		v, err := r.p.decode(iprot, wireType, r.f.Returns)
+		r.Success = v
+		return err
+	}
+	for _, field := range r.f.Throws {
+		if field.ID == id {
+			v, err := r.p.decode(iprot, wireType, field.Type)
+			r.Exception = &Exception{Field: field.Name, Type: field.Type.Name, Value: v}
+			return err
+		}
+	}
+	return iprot.Skip(wireType)
+}
+
+// Write is not supported, since Results are only used to make calls.
+func (r *Result) Write(oprot athrift.TProtocol) error {
+	return fmt.Errorf("writing %v_result is not supported", r.f.Name)
+}
+
+// wireType returns the Thrift type used to encode values of t.
+func (p *Program) wireType(t *Type) (athrift.TType, error) {
+	t = p.resolve(t)
+	switch t.Name {
+	case "bool":
+		return athrift.BOOL, nil
+	case "byte", "i8":
+		return athrift.BYTE, nil
+	case "i16":
+		return athrift.I16, nil
+	case "i32":
+		return athrift.I32, nil
+	case "i64":
+		return athrift.I64, nil
+	case "double":
+		return athrift.DOUBLE, nil
+	case "string", "binary", "slist":
+		return athrift.STRING, nil
+	case "list":
+		return athrift.LIST, nil
+	case "set":
+		return athrift.SET, nil
+	case "map":
+		return athrift.MAP, nil
+	}
+	if _, ok := p.Enums[t.Name]; ok {
+		return athrift.I32, nil
+	}
+	if _, ok := p.Structs[t.Name]; ok {
+		return athrift.STRUCT, nil
+	}
+	return athrift.STOP, fmt.Errorf("unknown type %v", t.Name)
+}
+
+// encode converts the JSON value v at path to a value of type t, and
+// returns a function to write it.
+func (p *Program) encode(path string, t *Type, v interface{}) (writeFunc, error) {
+	t = p.resolve(t)
+	switch t.Name {
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, typeError(path, t, v)
+		}
+		return func(oprot athrift.TProtocol) error { return oprot.WriteBool(b) }, nil
+	case "byte", "i8":
+		n, err := encodeInt(path, t, v, 8)
+		return func(oprot athrift.TProtocol) error { return oprot.WriteByte(int8(n)) }, err
+	case "i16":
+		n, err := encodeInt(path, t, v, 16)
+		return func(oprot athrift.TProtocol) error { return oprot.WriteI16(int16(n)) }, err
+	case "i32":
+		n, err := encodeInt(path, t, v, 32)
+		return func(oprot athrift.TProtocol) error { return oprot.WriteI32(int32(n)) }, err
+	case "i64":
+		n, err := encodeInt(path, t, v, 64)
+		return func(oprot athrift.TProtocol) error { return oprot.WriteI64(n) }, err
+	case "double":
+		num, ok := v.(json.Number)
+		if !ok {
+			return nil, typeError(path, t, v)
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid double %v", path, num)
+		}
+		return func(oprot athrift.TProtocol) error { return oprot.WriteDouble(f) }, nil
+	case "string", "slist":
+		s, ok := v.(string)
+		if !ok {
+			return nil, typeError(path, t, v)
+		}
+		return func(oprot athrift.TProtocol) error { return oprot.WriteString(s) }, nil
+	case "binary":
+		s, ok := v.(string)
+		if !ok {
+			return nil, typeError(path, t, v)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%v: binary values must be base64: %v", path, err)
+		}
+		return func(oprot athrift.TProtocol) error { return oprot.WriteBinary(b) }, nil
+	case "list", "set":
+		return p.encodeList(path, t, v)
+	case "map":
+		return p.encodeMap(path, t, v)
+	}
+
+	if e, ok := p.Enums[t.Name]; ok {
+		n, err := encodeEnum(path, e, v)
+		return func(oprot athrift.TProtocol) error { return oprot.WriteI32(n) }, err
+	}
+	if s, ok := p.Structs[t.Name]; ok {
+		write, err := p.encodeStruct(path, s.Fields, v)
+		if err != nil {
+			return nil, err
+		}
+		return func(oprot athrift.TProtocol) error {
+			if err := oprot.WriteStructBegin(s.Name); err != nil {
+				return err
+			}
+			return write(oprot)
+		}, nil
+	}
+	return nil, fmt.Errorf("%v: unknown type %v", path, t.Name)
+}
+
+// encodeStruct converts a JSON object to the given fields, and returns a
+// function that writes the fields and the end of the struct.
+func (p *Program) encodeStruct(path string, fields []*Field, v interface{}) (writeFunc, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v: expected an object, got %v", path, jsonKind(v))
+	}
+
+	byName := make(map[string]*Field, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+	for name := range obj {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("%v: unknown field %q", path, name)
+		}
+	}
+
+	var writes []writeFunc
+	for _, f := range fields {
+		fv, ok := obj[f.Name]
+		if !ok || fv == nil {
+			if f.Requiredness == Required {
+				return nil, fmt.Errorf("%v: missing required field %q", path, f.Name)
+			}
+			continue
+		}
+
+		wireType, err := p.wireType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%v.%v: %v", path, f.Name, err)
+		}
+		write, err := p.encode(path+"."+f.Name, f.Type, fv)
+		if err != nil {
+			return nil, err
+		}
+
+		f := f
+		writes = append(writes, func(oprot athrift.TProtocol) error {
+			if err := oprot.WriteFieldBegin(f.Name, wireType, int16(f.ID)); err != nil {
+				return err
+			}
+			if err := write(oprot); err != nil {
+				return err
+			}
+			return oprot.WriteFieldEnd()
+		})
+	}
+
+	return func(oprot athrift.TProtocol) error {
+		for _, write := range writes {
+			if err := write(oprot); err != nil {
+				return err
+			}
+		}
+		if err := oprot.WriteFieldStop(); err != nil {
+			return err
+		}
+		return oprot.WriteStructEnd()
+	}, nil
+}
+
+func (p *Program) encodeList(path string, t *Type, v interface{}) (writeFunc, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, typeError(path, t, v)
+	}
+	elemType, err := p.wireType(t.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	writes := make([]writeFunc, len(list))
+	for i, elem := range list {
+		if writes[i], err = p.encode(fmt.Sprintf("%v.%v", path, i), t.Value, elem); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(oprot athrift.TProtocol) error {
+		begin, end := oprot.WriteListBegin, oprot.WriteListEnd
+		if t.Name == "set" {
+			begin, end = oprot.WriteSetBegin, oprot.WriteSetEnd
+		}
+		if err := begin(elemType, len(writes)); err != nil {
+			return err
+		}
+		for _, write := range writes {
+			if err := write(oprot); err != nil {
+				return err
+			}
+		}
+		return end()
+	}, nil
+}
+
+func (p *Program) encodeMap(path string, t *Type, v interface{}) (writeFunc, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, typeError(path, t, v)
+	}
+	keyType, err := p.wireType(t.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	valueType, err := p.wireType(t.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	// Write entries in a stable order, since JSON objects are unordered.
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	writes := make([]writeFunc, 0, 2*len(keys))
+	for _, k := range keys {
+		elemPath := path + "." + k
+		key, err := p.mapKey(elemPath, t.Key, k)
+		if err != nil {
+			return nil, err
+		}
+		writeKey, err := p.encode(elemPath, t.Key, key)
+		if err != nil {
+			return nil, err
+		}
+		writeValue, err := p.encode(elemPath, t.Value, obj[k])
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, writeKey, writeValue)
+	}
+
+	return func(oprot athrift.TProtocol) error {
+		if err := oprot.WriteMapBegin(keyType, valueType, len(keys)); err != nil {
+			return err
+		}
+		for _, write := range writes {
+			if err := write(oprot); err != nil {
+				return err
+			}
+		}
+		return oprot.WriteMapEnd()
+	}, nil
+}
+
+// mapKey converts a JSON object key to the JSON value for a map key of
+// type t. Keys that aren't strings are parsed as JSON.
+func (p *Program) mapKey(path string, t *Type, k string) (interface{}, error) {
+	t = p.resolve(t)
+	switch t.Name {
+	case "string", "binary", "slist":
+		return k, nil
+	case "bool", "byte", "i8", "i16", "i32", "i64", "double":
+		var v interface{}
+		dec := json.NewDecoder(strings.NewReader(k))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("%v: invalid %v map key %q", path, t, k)
+		}
+		return v, nil
+	}
+	if e, ok := p.Enums[t.Name]; ok {
+		if _, ok := e.Values[k]; ok {
+			return k, nil
+		}
+		return json.Number(k), nil
+	}
+	return nil, fmt.Errorf("%v: map keys of type %v are not supported", path, t)
+}
+
+func encodeInt(path string, t *Type, v interface{}, bits int) (int64, error) {
+	num, ok := v.(json.Number)
+	if !ok {
+		return 0, typeError(path, t, v)
+	}
+	n, err := strconv.ParseInt(string(num), 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%v: invalid %v %v", path, t, num)
+	}
+	return n, nil
+}
+
+func encodeEnum(path string, e *Enum, v interface{}) (int32, error) {
+	switch v := v.(type) {
+	case string:
+		n, ok := e.Values[v]
+		if !ok {
+			return 0, fmt.Errorf("%v: unknown %v value %q", path, e.Name, v)
+		}
+		return int32(n), nil
+	case json.Number:
+		n, err := strconv.ParseInt(string(v), 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("%v: invalid %v value %v", path, e.Name, v)
+		}
+		return int32(n), nil
+	}
+	return 0, fmt.Errorf("%v: expected a name or number for %v, got %v", path, e.Name, jsonKind(v))
+}
+
+func typeError(path string, t *Type, v interface{}) error {
+	return fmt.Errorf("%v: expected %v, got %v", path, t, jsonKind(v))
+}
+
+// jsonKind describes the kind of a decoded JSON value for errors.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a bool"
+	case json.Number:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// decode reads a value of type t that was written with wireType, and returns
+// it as a value that can be marshalled as JSON. If t doesn't match the wire
+// type, the value is decoded without it, so structs are keyed by field ID.
+func (p *Program) decode(iprot athrift.TProtocol, wireType athrift.TType, t *Type) (interface{}, error) {
+	if t != nil {
+		t = p.resolve(t)
+		if expected, err := p.wireType(t); err != nil || expected != wireType {
+			t = nil
+		}
+	}
+
+	switch wireType {
+	case athrift.BOOL:
+		return iprot.ReadBool()
+	case athrift.BYTE:
+		n, err := iprot.ReadByte()
+		return int64(n), err
+	case athrift.I16:
+		n, err := iprot.ReadI16()
+		return int64(n), err
+	case athrift.I32:
+		n, err := iprot.ReadI32()
+		if err != nil || t == nil {
+			return int64(n), err
+		}
+		return p.decodeEnum(t, n), nil
+	case athrift.I64:
+		return iprot.ReadI64()
+	case athrift.DOUBLE:
+		return iprot.ReadDouble()
+	case athrift.STRING:
+		if t != nil && t.Name == "binary" {
+			return iprot.ReadBinary()
+		}
+		return iprot.ReadString()
+	case athrift.STRUCT:
+		var fields []*Field
+		if t != nil {
+			fields = p.Structs[t.Name].Fields
+		}
+		return p.decodeStruct(iprot, fields)
+	case athrift.LIST, athrift.SET:
+		return p.decodeList(iprot, wireType, t)
+	case athrift.MAP:
+		return p.decodeMap(iprot, t)
+	}
+	return nil, fmt.Errorf("unknown Thrift type %v", wireType)
+}
+
+// decodeEnum returns the name of the enum value n, or n if t isn't an enum or
+// n isn't a known value.
+func (p *Program) decodeEnum(t *Type, n int32) interface{} {
+	if e, ok := p.Enums[t.Name]; ok {
+		for name, value := range e.Values {
+			if int32(value) == n {
+				return name
+			}
+		}
+	}
+	return int64(n)
+}
+
+// decodeStruct reads a struct into a map keyed by field name. Fields that
+// aren't in fields are keyed by their ID.
+func (p *Program) decodeStruct(iprot athrift.TProtocol, fields []*Field) (map[string]interface{}, error) {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return nil, err
+	}
+
+	obj := make(map[string]interface{})
+	for {
+		_, wireType, id, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return nil, err
+		}
+		if wireType == athrift.STOP {
+			break
+		}
+
+		name, fieldType := strconv.Itoa(int(id)), (*Type)(nil)
+		for _, f := range fields {
+			if f.ID == int(id) {
+				name, fieldType = f.Name, f.Type
+			}
+		}
+		if obj[name], err = p.decode(iprot, wireType, fieldType); err != nil {
+			return nil, err
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return nil, err
+		}
+	}
+	return obj, iprot.ReadStructEnd()
+}
+
+func (p *Program) decodeList(iprot athrift.TProtocol, wireType athrift.TType, t *Type) ([]interface{}, error) {
+	begin, end := iprot.ReadListBegin, iprot.ReadListEnd
+	if wireType == athrift.SET {
+		begin, end = iprot.ReadSetBegin, iprot.ReadSetEnd
+	}
+	elemType, size, err := begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSize(iprot, size, minSize(elemType)); err != nil {
+		return nil, err
+	}
+
+	var elem *Type
+	if t != nil {
+		elem = t.Value
+	}
+	var list []interface{}
+	for i := 0; i < size; i++ {
+		v, err := p.decode(iprot, elemType, elem)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	if list == nil {
+		list = []interface{}{}
+	}
+	return list, end()
+}
+
+// decodeMap reads a map into a JSON object. Keys that aren't strings are
+// keyed by their JSON, e.g. "1" or "true".
+func (p *Program) decodeMap(iprot athrift.TProtocol, t *Type) (map[string]interface{}, error) {
+	keyType, valueType, size, err := iprot.ReadMapBegin()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSize(iprot, size, minSize(keyType)+minSize(valueType)); err != nil {
+		return nil, err
+	}
+
+	var key, value *Type
+	if t != nil {
+		key, value = t.Key, t.Value
+	}
+	obj := make(map[string]interface{})
+	for i := 0; i < size; i++ {
+		k, err := p.decode(iprot, keyType, key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.decode(iprot, valueType, value)
+		if err != nil {
+			return nil, err
+		}
+		obj[formatKey(k)] = v
+	}
+	return obj, iprot.ReadMapEnd()
+}
+
+// checkSize returns an error if size elements of at least elemSize bytes each
+// can't fit in the rest of the payload, so that a corrupt size isn't trusted.
+func checkSize(iprot athrift.TProtocol, size int, elemSize uint64) error {
+	trans, ok := iprot.Transport().(athrift.TRichTransport)
+	if !ok {
+		return nil
+	}
+	if remaining := trans.RemainingBytes(); uint64(size)*elemSize > remaining {
+		return fmt.Errorf("size %v is larger than the %v bytes left in the payload", size, remaining)
+	}
+	return nil
+}
+
+// minSize returns the fewest bytes that a value of wireType is encoded in.
+func minSize(wireType athrift.TType) uint64 {
+	switch wireType {
+	case athrift.I16:
+		return 2
+	case athrift.I32, athrift.STRING:
+		return 4
+	case athrift.I64, athrift.DOUBLE:
+		return 8
+	case athrift.LIST, athrift.SET:
+		return 5
+	case athrift.MAP:
+		return 6
+	}
+	return 1
+}
+
+// formatKey returns the JSON object key for a decoded map key.
+func formatKey(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(k)
+	if err != nil {
+		return fmt.Sprint(k)
+	}
+	return string(b)
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idl
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const codecIDL = `
+typedef i64 Timestamp
+
+enum Color {
+  RED,
+  GREEN = 5
+}
+
+struct Item {
+  1: required string name
+  2: optional list<Color> colors
+  3: optional map<i32, double> weights
+  4: optional binary data
+  5: optional Timestamp updated
+  6: optional map<Color, bool> flags
+}
+
+exception NotFound {
+  1: string key
+}
+
+service Base {
+  bool health()
+}
+
+service Store extends Base {
+  Item get(1: string key, 2: set<string> fields) throws (1: NotFound notFound)
+  void put(1: Item item)
+  oneway void forget(1: string key)
+}
+`
+
+func parseCodecIDL(t *testing.T) *Program {
+	p, err := Parse(codecIDL)
+	require.NoError(t, err, "Parse failed")
+	return p
+}
+
+func TestMethod(t *testing.T) {
+	p := parseCodecIDL(t)
+
+	tests := []struct {
+		method      string
+		wantService string
+		wantFunc    string
+		wantErr     string
+	}{
+		{method: "Store::get", wantService: "Store", wantFunc: "get"},
+		{method: "Store::health", wantService: "Store", wantFunc: "health"},
+		{method: "get", wantErr: "must be of the form Service::function"},
+		{method: "Store::", wantErr: "must be of the form Service::function"},
+		{method: "Cache::get", wantErr: `unknown service "Cache"`},
+		{method: "Base::get", wantErr: `unknown function "get" in service Base`},
+		{method: "Store::forget", wantErr: "oneway"},
+	}
+
+	for _, tt := range tests {
+		service, f, err := p.Method(tt.method)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "Expected error for %v", tt.method) {
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %v", tt.method)
+			}
+			continue
+		}
+		require.NoError(t, err, "Method failed for %v", tt.method)
+		assert.Equal(t, tt.wantService, service, "Unexpected service for %v", tt.method)
+		assert.Equal(t, tt.wantFunc, f.Name, "Unexpected function for %v", tt.method)
+	}
+}
+
+// writeArgs writes args for the function, and decodes them again using the
+// function's argument types.
+func writeArgs(t *testing.T, p *Program, f *Function, args string) map[string]interface{} {
+	a, err := p.NewArgs(f, []byte(args))
+	require.NoError(t, err, "NewArgs failed for %v", args)
+
+	prot := athrift.NewTBinaryProtocolTransport(athrift.NewTMemoryBuffer())
+	require.NoError(t, a.Write(prot), "Write failed for %v", args)
+
+	decoded, err := p.decodeStruct(prot, f.Args)
+	require.NoError(t, err, "Failed to decode args for %v", args)
+	return decoded
+}
+
+func TestArgs(t *testing.T) {
+	p := parseCodecIDL(t)
+	_, get, err := p.Method("Store::get")
+	require.NoError(t, err, "Method failed")
+	_, put, err := p.Method("Store::put")
+	require.NoError(t, err, "Method failed")
+
+	assert.Equal(t, map[string]interface{}{
+		"key":    "k",
+		"fields": []interface{}{"name", "colors"},
+	}, writeArgs(t, p, get, `{"key": "k", "fields": ["name", "colors"]}`))
+
+	assert.Equal(t, map[string]interface{}{}, writeArgs(t, p, get, `{"key": null}`), "Null arguments should be omitted")
+	assert.Equal(t, map[string]interface{}{}, writeArgs(t, p, get, `null`), "Null should be no arguments")
+
+	assert.Equal(t, map[string]interface{}{
+		"item": map[string]interface{}{
+			"name":    "x",
+			"colors":  []interface{}{"RED", "GREEN", int64(7)},
+			"weights": map[string]interface{}{"1": 0.5, "-2": 1.0},
+			"data":    []byte("hi"),
+			"updated": int64(1234567890123456789),
+			"flags":   map[string]interface{}{"GREEN": true},
+		},
+	}, writeArgs(t, p, put, `{"item": {
+		"name": "x",
+		"colors": ["RED", 5, 7],
+		"weights": {"1": 0.5, "-2": 1},
+		"data": "aGk=",
+		"updated": 1234567890123456789,
+		"flags": {"5": true}
+	}}`))
+}
+
+func TestArgsErrors(t *testing.T) {
+	p := parseCodecIDL(t)
+	_, put, err := p.Method("Store::put")
+	require.NoError(t, err, "Method failed")
+
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{`{`, "invalid JSON"},
+		{`{} {}`, "unexpected data after the arguments"},
+		{`[]`, "args: expected an object, got an array"},
+		{`{"itm": {}}`, `args: unknown field "itm"`},
+		{`{"item": {}}`, `args.item: missing required field "name"`},
+		{`{"item": {"name": 1}}`, "args.item.name: expected string, got a number"},
+		{`{"item": {"name": "x", "colors": ["BLUE"]}}`, `args.item.colors.0: unknown Color value "BLUE"`},
+		{`{"item": {"name": "x", "colors": [true]}}`, "args.item.colors.0: expected a name or number for Color, got a bool"},
+		{`{"item": {"name": "x", "weights": {"a": 1}}}`, `args.item.weights.a: invalid i32 map key "a"`},
+		{`{"item": {"name": "x", "weights": {"1": "a"}}}`, "args.item.weights.1: expected double, got a string"},
+		{`{"item": {"name": "x", "data": "!"}}`, "args.item.data: binary values must be base64"},
+		{`{"item": {"name": "x", "updated": 1.5}}`, "args.item.updated: invalid i64 1.5"},
+		{`{"item": {"name": "x", "colors": {}}}`, "args.item.colors: expected list<Color>, got an object"},
+	}
+
+	for _, tt := range tests {
+		_, err := p.NewArgs(put, []byte(tt.args))
+		if assert.Error(t, err, "Expected error for %v", tt.args) {
+			assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %v", tt.args)
+		}
+	}
+}
+
+// writeResult writes a result struct with the given fields and JSON values,
+// and reads it as the result of f.
+func writeResult(t *testing.T, p *Program, f *Function, fields []*Field, values string) *Result {
+	write, err := p.encodeStruct("result", fields, decodeJSON(t, values))
+	require.NoError(t, err, "Failed to encode result %v", values)
+
+	prot := athrift.NewTBinaryProtocolTransport(athrift.NewTMemoryBuffer())
+	require.NoError(t, prot.WriteStructBegin("result"), "WriteStructBegin failed")
+	require.NoError(t, write(prot), "Failed to write result %v", values)
+
+	r := p.NewResult(f)
+	require.NoError(t, r.Read(prot), "Failed to read result %v", values)
+	return r
+}
+
+// decodeJSON decodes s the same way as NewArgs.
+func decodeJSON(t *testing.T, s string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var v interface{}
+	require.NoError(t, dec.Decode(&v), "Failed to decode %v", s)
+	return v
+}
+
+func TestResult(t *testing.T) {
+	p := parseCodecIDL(t)
+	_, get, err := p.Method("Store::get")
+	require.NoError(t, err, "Method failed")
+	_, put, err := p.Method("Store::put")
+	require.NoError(t, err, "Method failed")
+
+	success := []*Field{{ID: 0, Name: "success", Type: get.Returns}}
+	r := writeResult(t, p, get, success, `{"success": {"name": "x", "colors": ["GREEN"]}}`)
+	assert.Equal(t, map[string]interface{}{"name": "x", "colors": []interface{}{"GREEN"}}, r.Success, "Unexpected success")
+	assert.Nil(t, r.Exception, "Unexpected exception")
+
+	r = writeResult(t, p, get, get.Throws, `{"notFound": {"key": "k"}}`)
+	assert.Nil(t, r.Success, "Unexpected success")
+	require.NotNil(t, r.Exception, "Expected exception")
+	assert.Equal(t, &Exception{Field: "notFound", Type: "NotFound", Value: map[string]interface{}{"key": "k"}}, r.Exception)
+	assert.Equal(t, `NotFound (notFound): {"key":"k"}`, r.Exception.String())
+
+	r = writeResult(t, p, put, nil, `{}`)
+	assert.Nil(t, r.Success, "Void functions should have no result")
+	assert.Nil(t, r.Exception, "Unexpected exception")
+
+	// Fields that don't match the IDL are decoded without names, and fields
+	// that aren't part of the result are skipped.
+	mismatched := []*Field{
+		{ID: 0, Name: "success", Type: &Type{Name: "map", Key: &Type{Name: "i16"}, Value: &Type{Name: "binary"}}},
+		{ID: 7, Name: "other", Type: &Type{Name: "string"}},
+	}
+	r = writeResult(t, p, get, mismatched, `{"success": {"3": "aGk="}, "other": "x"}`)
+	assert.Equal(t, map[string]interface{}{"3": "hi"}, r.Success, "Unexpected success")
+	assert.Nil(t, r.Exception, "Unexpected exception")
+}
+
+func TestResultCorruptSize(t *testing.T) {
+	p := parseCodecIDL(t)
+	_, get, err := p.Method("Store::get")
+	require.NoError(t, err, "Method failed")
+
+	tests := []struct {
+		msg       string
+		fieldType athrift.TType
+		write     func(athrift.TProtocol) error
+	}{
+		{"list", athrift.LIST, func(prot athrift.TProtocol) error { return prot.WriteListBegin(athrift.I64, math.MaxInt32) }},
+		{"set", athrift.SET, func(prot athrift.TProtocol) error { return prot.WriteSetBegin(athrift.BYTE, 1<<30) }},
+		{"map", athrift.MAP, func(prot athrift.TProtocol) error { return prot.WriteMapBegin(athrift.STRING, athrift.STRING, 1<<30) }},
+	}
+
+	for _, tt := range tests {
+		prot := athrift.NewTBinaryProtocolTransport(athrift.NewTMemoryBuffer())
+		require.NoError(t, prot.WriteFieldBegin("success", tt.fieldType, 0), "WriteFieldBegin failed")
+		require.NoError(t, tt.write(prot), "Failed to write %v header", tt.msg)
+		// A few elements follow, nowhere near as many as the size claims.
+		_, err := prot.Transport().Write([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+		require.NoError(t, err, "Write failed")
+
+		err = p.NewResult(get).Read(prot)
+		require.Error(t, err, "%v: expected corrupt size to fail", tt.msg)
+		assert.Contains(t, err.Error(), "bytes left in the payload", "%v: unexpected error", tt.msg)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Change is a difference between two IDLs.
+type Change struct {
+	// Breaking is whether clients or servers built with the old IDL may fail
+	// to interoperate with those built with the new IDL.
+	Breaking bool
+
+	// Kind is one of "added", "removed" or "changed".
+	Kind string
+
+	// Element is what changed, e.g. "service", "method" or "field".
+	Element string
+
+	// Name is the qualified name of the element, e.g. "Meta::health".
+	Name string
+
+	// Detail describes how a changed element changed.
+	Detail string
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%v %v %v", c.Kind, c.Element, c.Name)
+	if c.Detail != "" {
+		s += ": " + c.Detail
+	}
+	return s
+}
+
+// Diff returns the changes from the old IDL to the new IDL, sorted with
+// breaking changes first.
+func Diff(old, new *Program) []Change {
+	d := &differ{old: old, new: new}
+	d.services()
+	d.structs()
+	d.enums()
+
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		ci, cj := d.changes[i], d.changes[j]
+		if ci.Breaking != cj.Breaking {
+			return ci.Breaking
+		}
+		return ci.Name < cj.Name
+	})
+	return d.changes
+}
+
+type differ struct {
+	old, new *Program
+	changes  []Change
+}
+
+func (d *differ) add(breaking bool, kind, element, name, detailFormat string, args ...interface{}) {
+	d.changes = append(d.changes, Change{
+		Breaking: breaking,
+		Kind:     kind,
+		Element:  element,
+		Name:     name,
+		Detail:   fmt.Sprintf(detailFormat, args...),
+	})
+}
+
+func sortedKeys(names map[string]bool) []string {
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *differ) services() {
+	names := make(map[string]bool)
+	for name := range d.old.Services {
+		names[name] = true
+	}
+	for name := range d.new.Services {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		oldSvc, newSvc := d.old.Services[name], d.new.Services[name]
+		switch {
+		case newSvc == nil:
+			d.add(true, "removed", "service", name, "")
+		case oldSvc == nil:
+			d.add(false, "added", "service", name, "")
+		default:
+			d.functions(name, d.old.functions(oldSvc), d.new.functions(newSvc))
+		}
+	}
+}
+
+func (d *differ) functions(service string, old, new map[string]*Function) {
+	names := make(map[string]bool)
+	for name := range old {
+		names[name] = true
+	}
+	for name := range new {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		oldFn, newFn := old[name], new[name]
+		qualified := service + "::" + name
+		switch {
+		case newFn == nil:
+			d.add(true, "removed", "method", qualified, "")
+			continue
+		case oldFn == nil:
+			d.add(false, "added", "method", qualified, "")
+			continue
+		}
+
+		if oldFn.Oneway != newFn.Oneway {
+			d.add(true, "changed", "method", qualified, "oneway changed from %v to %v", oldFn.Oneway, newFn.Oneway)
+		}
+		if oldType, newType := typeName(d.old, oldFn.Returns), typeName(d.new, newFn.Returns); oldType != newType {
+			d.add(true, "changed", "method", qualified, "return type changed from %v to %v", oldType, newType)
+		}
+		d.fields(qualified, "argument", oldFn.Args, newFn.Args)
+		d.fields(qualified, "exception", oldFn.Throws, newFn.Throws)
+	}
+}
+
+func (d *differ) structs() {
+	names := make(map[string]bool)
+	for name := range d.old.Structs {
+		names[name] = true
+	}
+	for name := range d.new.Structs {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		oldStruct, newStruct := d.old.Structs[name], d.new.Structs[name]
+		switch {
+		case newStruct == nil:
+			// Types are only breaking if they're used, which is caught
+			// when comparing the methods or fields that use them.
+			d.add(false, "removed", "type", name, "")
+		case oldStruct == nil:
+			d.add(false, "added", "type", name, "")
+		default:
+			if oldStruct.Kind != newStruct.Kind {
+				d.add(true, "changed", "type", name, "changed from %v to %v", oldStruct.Kind, newStruct.Kind)
+			}
+			d.fields(name, "field", oldStruct.Fields, newStruct.Fields)
+		}
+	}
+}
+
+// fields compares the fields of a struct, or the arguments or exceptions of
+// a method. Fields are identified on the wire by their ID, so a field that's
+// renamed is compatible, but a field that changes ID is not.
+func (d *differ) fields(parent, element string, old, new []*Field) {
+	oldByID := make(map[int]*Field)
+	for _, f := range old {
+		oldByID[f.ID] = f
+	}
+	newByID := make(map[int]*Field)
+	newByName := make(map[string]*Field)
+	for _, f := range new {
+		newByID[f.ID] = f
+		newByName[f.Name] = f
+	}
+
+	for _, oldField := range old {
+		name := parent + "." + oldField.Name
+		newField, ok := newByID[oldField.ID]
+		if !ok {
+			if moved, ok := newByName[oldField.Name]; ok {
+				d.add(true, "changed", element, name, "ID changed from %v to %v", oldField.ID, moved.ID)
+			} else {
+				d.add(oldField.Requiredness == Required, "removed", element, name, "ID %v", oldField.ID)
+			}
+			continue
+		}
+
+		if oldField.Name != newField.Name {
+			d.add(false, "changed", element, name, "renamed to %v", newField.Name)
+		}
+		if oldType, newType := typeName(d.old, oldField.Type), typeName(d.new, newField.Type); oldType != newType {
+			d.add(true, "changed", element, name, "type changed from %v to %v", oldType, newType)
+		}
+		if oldField.Requiredness != newField.Requiredness {
+			// Making a field required breaks senders that omit it, and
+			// making it optional breaks receivers that rely on it.
+			breaking := oldField.Requiredness == Required || newField.Requiredness == Required
+			d.add(breaking, "changed", element, name, "changed from %v to %v", oldField.Requiredness, newField.Requiredness)
+		}
+	}
+
+	for _, newField := range new {
+		if _, ok := oldByID[newField.ID]; ok {
+			continue
+		}
+		if oldField := findByName(old, newField.Name); oldField != nil {
+			if _, ok := newByID[oldField.ID]; !ok {
+				// Already reported as an ID change.
+				continue
+			}
+		}
+		d.add(newField.Requiredness == Required, "added", element, parent+"."+newField.Name, "ID %v", newField.ID)
+	}
+}
+
+func findByName(fields []*Field, name string) *Field {
+	for _, f := range fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (d *differ) enums() {
+	names := make(map[string]bool)
+	for name := range d.old.Enums {
+		names[name] = true
+	}
+	for name := range d.new.Enums {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		oldEnum, newEnum := d.old.Enums[name], d.new.Enums[name]
+		switch {
+		case newEnum == nil:
+			d.add(false, "removed", "type", name, "")
+		case oldEnum == nil:
+			d.add(false, "added", "type", name, "")
+		default:
+			d.enumValues(name, oldEnum, newEnum)
+		}
+	}
+}
+
+func (d *differ) enumValues(enum string, old, new *Enum) {
+	names := make(map[string]bool)
+	for name := range old.Values {
+		names[name] = true
+	}
+	for name := range new.Values {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		oldValue, inOld := old.Values[name]
+		newValue, inNew := new.Values[name]
+		qualified := enum + "." + name
+		switch {
+		case !inNew:
+			d.add(true, "removed", "enum value", qualified, "value %v", oldValue)
+		case !inOld:
+			d.add(false, "added", "enum value", qualified, "value %v", newValue)
+		case oldValue != newValue:
+			d.add(true, "changed", "enum value", qualified, "value changed from %v to %v", oldValue, newValue)
+		}
+	}
+}
+
+// typeName returns the name of the type with typedefs resolved, or "void".
+func typeName(p *Program, t *Type) string {
+	if t == nil {
+		return "void"
+	}
+	return p.resolve(t).String()
+}
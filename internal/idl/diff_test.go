@@ -0,0 +1,185 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, src string) *Program {
+	prog, err := Parse(src)
+	require.NoError(t, err, "Parse failed for:\n%v", src)
+	return prog
+}
+
+func TestDiff(t *testing.T) {
+	const base = `
+typedef string UUID
+enum Status { OK, FAIL }
+struct Request {
+  1: required UUID id
+  2: optional string name
+}
+service Store {
+  Status get(1: Request req)
+  void put(1: Request req)
+}
+`
+
+	tests := []struct {
+		msg  string
+		idl  string
+		want []string
+	}{
+		{
+			msg:  "no changes",
+			idl:  base,
+			want: nil,
+		},
+		{
+			msg: "equivalent IDL with typedef expanded and fields renamed",
+			idl: `
+enum Status { OK = 0, FAIL = 1 }
+struct Request {
+  1: required string uuid
+  2: optional string name
+}
+service Store {
+  Status get(1: Request request)
+  void put(1: Request req)
+}
+`,
+			want: []string{
+				"compatible changed field Request.id: renamed to uuid",
+				"compatible changed argument Store::get.req: renamed to request",
+			},
+		},
+		{
+			msg: "compatible additions",
+			idl: `
+typedef string UUID
+enum Status { OK, FAIL, WARN }
+struct Request {
+  1: required UUID id
+  2: optional string name
+  3: optional i64 ttl
+}
+struct Extra {}
+service Store {
+  Status get(1: Request req)
+  void put(1: Request req)
+  void delete(1: optional Request req)
+}
+service Admin {}
+`,
+			want: []string{
+				"compatible added service Admin",
+				"compatible added type Extra",
+				"compatible added field Request.ttl: ID 3",
+				"compatible added enum value Status.WARN: value 2",
+				"compatible added method Store::delete",
+			},
+		},
+		{
+			msg: "breaking changes",
+			idl: `
+enum Status { FAIL, OK }
+struct Request {
+  1: required i64 id
+  3: optional string name
+  4: required string owner
+}
+service Store {
+  oneway void get(1: Request req)
+}
+`,
+			want: []string{
+				"BREAKING changed field Request.id: type changed from string to i64",
+				"BREAKING changed field Request.name: ID changed from 2 to 3",
+				"BREAKING added field Request.owner: ID 4",
+				"BREAKING changed enum value Status.FAIL: value changed from 1 to 0",
+				"BREAKING changed enum value Status.OK: value changed from 0 to 1",
+				"BREAKING changed method Store::get: oneway changed from false to true",
+				"BREAKING changed method Store::get: return type changed from Status to void",
+				"BREAKING removed method Store::put",
+			},
+		},
+		{
+			msg: "requiredness changes",
+			idl: `
+typedef string UUID
+enum Status { OK, FAIL }
+struct Request {
+  1: UUID id
+  2: required string name
+}
+service Store {
+  Status get(1: Request req)
+  void put(1: Request req)
+}
+`,
+			want: []string{
+				"BREAKING changed field Request.id: changed from required to default",
+				"BREAKING changed field Request.name: changed from optional to required",
+			},
+		},
+		{
+			msg: "renamed field with its old name re-added",
+			idl: `
+typedef string UUID
+enum Status { OK, FAIL }
+struct Request {
+  1: required UUID id
+  2: optional string displayName
+  3: optional string name
+}
+service Store {
+  Status get(1: Request req)
+  void put(1: Request req)
+}
+`,
+			want: []string{
+				"compatible changed field Request.name: renamed to displayName",
+				"compatible added field Request.name: ID 3",
+			},
+		},
+		{
+			msg:  "removed service",
+			idl:  "typedef string UUID\nenum Status { OK, FAIL }",
+			want: []string{"BREAKING removed service Store", "compatible removed type Request"},
+		},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, c := range Diff(mustParse(t, base), mustParse(t, tt.idl)) {
+			prefix := "compatible "
+			if c.Breaking {
+				prefix = "BREAKING "
+			}
+			got = append(got, prefix+c.String())
+		}
+		assert.Equal(t, tt.want, got, "%v: unexpected changes", tt.msg)
+	}
+}
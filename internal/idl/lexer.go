@@ -0,0 +1,129 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of file"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits a Thrift IDL into tokens, dropping whitespace and comments.
+func lex(src string) ([]token, error) {
+	var (
+		tokens []token
+		line   = 1
+		runes  = []rune(src)
+	)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' || (r == '/' && i+1 < len(runes) && runes[i+1] == '/'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			start := line
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+				if runes[i] == '\n' {
+					line++
+				}
+			}
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("line %v: unterminated comment", start)
+			}
+			i += 2
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("line %v: unterminated string", line)
+			}
+			i++
+			text := string(runes[start+1 : i-1])
+			tokens = append(tokens, token{tokenString, text, line})
+			line += strings.Count(text, "\n")
+		case isIdentStart(r):
+			start := i
+			for i < len(runes) && isIdentPart(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), line})
+		case unicode.IsDigit(r) || ((r == '-' || r == '+') && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (isIdentPart(runes[i]) || runes[i] == '+' || runes[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), line})
+		case strings.ContainsRune("{}()<>[],;:=*", r):
+			tokens = append(tokens, token{tokenSymbol, string(r), line})
+			i++
+		default:
+			return nil, fmt.Errorf("line %v: unexpected character %q", line, r)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, line: line}), nil
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
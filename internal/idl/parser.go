@@ -0,0 +1,553 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a single Thrift IDL that has no includes.
+func Parse(src string) (*Program, error) {
+	const filename = "idl.thrift"
+	return ParseFiles(map[string]string{filename: src}, filename)
+}
+
+// ParseFiles parses the Thrift IDL entryPoint, resolving includes against
+// files, which maps filenames to their contents. Includes are relative to
+// the directory of the including file.
+func ParseFiles(files map[string]string, entryPoint string) (*Program, error) {
+	return parseProgram(entryPoint, func(filename string) (string, error) {
+		src, ok := files[filename]
+		if !ok {
+			return "", fmt.Errorf("missing IDL file %v", filename)
+		}
+		return src, nil
+	})
+}
+
+// ParseFile parses the Thrift IDL at filename, reading includes from disk.
+func ParseFile(filename string) (*Program, error) {
+	return parseProgram(filepath.ToSlash(filename), func(filename string) (string, error) {
+		src, err := ioutil.ReadFile(filepath.FromSlash(filename))
+		return string(src), err
+	})
+}
+
+// loadFunc returns the contents of an IDL file.
+type loadFunc func(filename string) (string, error)
+
+func parseProgram(entryPoint string, load loadFunc) (*Program, error) {
+	prog := newProgram()
+	parsed := make(map[string]bool)
+	if err := parseFile(prog, load, entryPoint, "", parsed); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// includePrefix returns the name used to refer to definitions in an
+// included file, which is the filename without the directory or extension.
+func includePrefix(filename string) string {
+	return strings.TrimSuffix(path.Base(filename), ".thrift")
+}
+
+func parseFile(prog *Program, load loadFunc, filename, prefix string, parsed map[string]bool) error {
+	if parsed[filename] {
+		return nil
+	}
+	parsed[filename] = true
+
+	src, err := load(filename)
+	if err != nil {
+		return err
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return fmt.Errorf("%v: %v", filename, err)
+	}
+
+	p := &parser{
+		prog:     prog,
+		tokens:   tokens,
+		prefix:   prefix,
+		includes: make(map[string]string),
+	}
+	if err := p.parse(); err != nil {
+		return fmt.Errorf("%v: %v", filename, err)
+	}
+
+	for _, include := range p.includeFiles {
+		includeName := path.Join(path.Dir(filename), include)
+		if err := parseFile(prog, load, includeName, includePrefix(include), parsed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type parser struct {
+	prog   *Program
+	tokens []token
+	pos    int
+
+	// prefix qualifies the names of definitions in this file.
+	prefix string
+
+	// includes maps the names used to refer to included files in this file
+	// to their prefixes, so that references can be qualified consistently.
+	includes     map[string]string
+	includeFiles []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %v: %v", t.line, fmt.Sprintf(format, args...))
+}
+
+// accept consumes the next token if it's the given symbol or keyword.
+func (p *parser) accept(text string) bool {
+	if t := p.peek(); (t.kind == tokenSymbol || t.kind == tokenIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.peek(); !p.accept(text) {
+		return p.errorf(t, "expected %q, got %v", text, t)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return "", p.errorf(t, "expected identifier, got %v", t)
+	}
+	return t.text, nil
+}
+
+// qualify returns the qualified name for a definition in this file.
+func (p *parser) qualify(name string) string {
+	if p.prefix == "" {
+		return name
+	}
+	return p.prefix + "." + name
+}
+
+// reference returns the qualified name for a reference to a named type.
+func (p *parser) reference(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		if prefix, ok := p.includes[name[:i]]; ok {
+			return prefix + name[i:]
+		}
+		return name
+	}
+	return p.qualify(name)
+}
+
+// skipSeparator skips an optional list separator.
+func (p *parser) skipSeparator() {
+	if !p.accept(",") {
+		p.accept(";")
+	}
+}
+
+// skipAnnotations skips optional annotations, e.g. (go.tag = "json").
+func (p *parser) skipAnnotations() error {
+	if p.peek().text != "(" {
+		return nil
+	}
+	return p.skipBalanced("(", ")")
+}
+
+// skipBalanced skips tokens from an opening symbol to its matching close.
+func (p *parser) skipBalanced(open, close string) error {
+	start := p.peek()
+	depth := 0
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			return p.errorf(start, "unterminated %q", open)
+		case t.kind != tokenSymbol:
+		case t.text == open:
+			depth++
+		case t.text == close:
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// skipConstValue skips a constant value, which may be a list or map.
+func (p *parser) skipConstValue() error {
+	switch p.peek().text {
+	case "[":
+		return p.skipBalanced("[", "]")
+	case "{":
+		return p.skipBalanced("{", "}")
+	}
+	if t := p.next(); t.kind == tokenEOF {
+		return p.errorf(t, "expected value, got %v", t)
+	}
+	return nil
+}
+
+func (p *parser) parse() error {
+	for {
+		t := p.next()
+		if t.kind == tokenEOF {
+			return nil
+		}
+		if t.kind != tokenIdent {
+			return p.errorf(t, "expected definition, got %v", t)
+		}
+
+		var err error
+		switch t.text {
+		case "include":
+			err = p.parseInclude()
+		case "cpp_include":
+			err = p.skipString()
+		case "namespace":
+			err = p.skipNamespace()
+		case "const":
+			err = p.skipConst()
+		case "typedef":
+			err = p.parseTypedef()
+		case "enum":
+			err = p.parseEnum()
+		case "senum":
+			err = p.skipSenum()
+		case "struct", "union", "exception":
+			err = p.parseStruct(t.text)
+		case "service":
+			err = p.parseService()
+		default:
+			err = p.errorf(t, "unknown definition %v", t)
+		}
+		if err != nil {
+			return err
+		}
+		p.skipSeparator()
+	}
+}
+
+func (p *parser) parseInclude() error {
+	t := p.next()
+	if t.kind != tokenString {
+		return p.errorf(t, "expected include filename, got %v", t)
+	}
+	p.includes[includePrefix(t.text)] = includePrefix(t.text)
+	p.includeFiles = append(p.includeFiles, t.text)
+	return nil
+}
+
+func (p *parser) skipString() error {
+	if t := p.next(); t.kind != tokenString {
+		return p.errorf(t, "expected string, got %v", t)
+	}
+	return nil
+}
+
+func (p *parser) skipNamespace() error {
+	if p.accept("*") {
+		_, err := p.ident()
+		return err
+	}
+	if _, err := p.ident(); err != nil {
+		return err
+	}
+	if _, err := p.ident(); err != nil {
+		return err
+	}
+	return p.skipAnnotations()
+}
+
+func (p *parser) skipConst() error {
+	if _, err := p.parseType(); err != nil {
+		return err
+	}
+	if _, err := p.ident(); err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	return p.skipConstValue()
+}
+
+func (p *parser) parseTypedef() error {
+	typ, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	p.prog.Typedefs[p.qualify(name)] = typ
+	return p.skipAnnotations()
+}
+
+func (p *parser) parseEnum() error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	enum := &Enum{Name: p.qualify(name), Values: make(map[string]int)}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	next := 0
+	for !p.accept("}") {
+		valueName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if p.accept("=") {
+			t := p.next()
+			v, err := strconv.ParseInt(t.text, 0, 32)
+			if err != nil {
+				return p.errorf(t, "invalid enum value %v", t)
+			}
+			next = int(v)
+		}
+		enum.Values[valueName] = next
+		next++
+
+		if err := p.skipAnnotations(); err != nil {
+			return err
+		}
+		p.skipSeparator()
+	}
+
+	p.prog.Enums[enum.Name] = enum
+	return p.skipAnnotations()
+}
+
+func (p *parser) skipSenum() error {
+	if _, err := p.ident(); err != nil {
+		return err
+	}
+	return p.skipBalanced("{", "}")
+}
+
+func (p *parser) parseStruct(kind string) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	fields, err := p.parseFields("{", "}")
+	if err != nil {
+		return err
+	}
+
+	s := &Struct{Name: p.qualify(name), Kind: kind, Fields: fields}
+	p.prog.Structs[s.Name] = s
+	return p.skipAnnotations()
+}
+
+// parseFields parses a list of fields between open and close.
+func (p *parser) parseFields(open, close string) ([]*Field, error) {
+	if err := p.expect(open); err != nil {
+		return nil, err
+	}
+
+	var fields []*Field
+	for !p.accept(close) {
+		field, err := p.parseField(len(fields))
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (p *parser) parseField(index int) (*Field, error) {
+	// Fields without an explicit ID are assigned negative IDs by Thrift.
+	field := &Field{ID: -(index + 1)}
+	if t := p.peek(); t.kind == tokenNumber {
+		p.next()
+		id, err := strconv.ParseInt(t.text, 0, 16)
+		if err != nil {
+			return nil, p.errorf(t, "invalid field ID %v", t)
+		}
+		field.ID = int(id)
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case p.accept("required"):
+		field.Requiredness = Required
+	case p.accept("optional"):
+		field.Requiredness = Optional
+	}
+
+	var err error
+	if field.Type, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if field.Name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if p.accept("=") {
+		if err := p.skipConstValue(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipAnnotations(); err != nil {
+		return nil, err
+	}
+	p.skipSeparator()
+	return field, nil
+}
+
+func (p *parser) parseType() (*Type, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return nil, p.errorf(t, "expected type, got %v", t)
+	}
+
+	var typ *Type
+	switch t.text {
+	case "map":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		value, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		typ = &Type{Name: "map", Key: key, Value: value}
+	case "list", "set":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		typ = &Type{Name: t.text, Value: value}
+	default:
+		if baseTypes[t.text] {
+			typ = &Type{Name: t.text}
+		} else {
+			typ = &Type{Name: p.reference(t.text)}
+		}
+	}
+
+	return typ, p.skipAnnotations()
+}
+
+func (p *parser) parseService() error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	svc := &Service{Name: p.qualify(name), Functions: make(map[string]*Function)}
+	if p.accept("extends") {
+		extends, err := p.ident()
+		if err != nil {
+			return err
+		}
+		svc.Extends = p.reference(extends)
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for !p.accept("}") {
+		f, err := p.parseFunction()
+		if err != nil {
+			return err
+		}
+		svc.Functions[f.Name] = f
+	}
+
+	p.prog.Services[svc.Name] = svc
+	return p.skipAnnotations()
+}
+
+func (p *parser) parseFunction() (*Function, error) {
+	f := &Function{Oneway: p.accept("oneway")}
+	if !p.accept("void") {
+		var err error
+		if f.Returns, err = p.parseType(); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if f.Name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if f.Args, err = p.parseFields("(", ")"); err != nil {
+		return nil, err
+	}
+	if p.accept("throws") {
+		if f.Throws, err = p.parseFields("(", ")"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipAnnotations(); err != nil {
+		return nil, err
+	}
+	p.skipSeparator()
+	return f, nil
+}
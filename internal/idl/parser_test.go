@@ -0,0 +1,164 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIDL = `
+# Comments in all styles are ignored.
+namespace java com.uber.test
+include "shared.thrift"
+
+/*
+ * Constants are skipped.
+ */
+const list<string> names = ["a", "b"]
+const map<string, i32> ids = {"a": 1, "b": -2}
+
+typedef string UUID
+
+enum Status {
+  OK,
+  WARN = 5,
+  FAIL
+}
+
+struct Request {
+  1: required UUID id
+  2: optional list<map<string, shared.Value>> values = [] (go.tag = "json:\"values\"")
+  3: Status status = Status.OK;
+}
+
+exception NotFound {
+  1: string message
+}
+
+service Store extends shared.Base {
+  shared.Value get(1: UUID id) throws (1: NotFound notFound)
+  oneway void put(1: Request req),
+  void ping()
+} (owner = "test")
+`
+
+const sharedIDL = `
+typedef binary Value
+
+service Base {
+  bool health()
+}
+`
+
+func TestParseFiles(t *testing.T) {
+	files := map[string]string{
+		"idl/store.thrift":  testIDL,
+		"idl/shared.thrift": sharedIDL,
+	}
+	prog, err := ParseFiles(files, "idl/store.thrift")
+	require.NoError(t, err, "ParseFiles failed")
+
+	assert.Equal(t, &Type{Name: "binary"}, prog.Typedefs["shared.Value"], "included typedef")
+	assert.Equal(t, map[string]int{"OK": 0, "WARN": 5, "FAIL": 6}, prog.Enums["Status"].Values, "enum values")
+
+	req := prog.Structs["Request"]
+	require.NotNil(t, req, "missing struct Request")
+	require.Len(t, req.Fields, 3, "Request fields")
+	assert.Equal(t, &Field{ID: 1, Name: "id", Type: &Type{Name: "UUID"}, Requiredness: Required}, req.Fields[0])
+	assert.Equal(t, Optional, req.Fields[1].Requiredness)
+	assert.Equal(t, "list<map<string, shared.Value>>", req.Fields[1].Type.String())
+	assert.Equal(t, Default, req.Fields[2].Requiredness)
+	assert.Equal(t, "exception", prog.Structs["NotFound"].Kind)
+
+	store := prog.Services["Store"]
+	require.NotNil(t, store, "missing service Store")
+	assert.Equal(t, "shared.Base", store.Extends)
+
+	get := store.Functions["get"]
+	require.NotNil(t, get, "missing function get")
+	assert.Equal(t, "shared.Value", get.Returns.Name)
+	assert.Len(t, get.Args, 1, "get args")
+	assert.Equal(t, "NotFound", get.Throws[0].Type.Name)
+
+	put := store.Functions["put"]
+	require.NotNil(t, put, "missing function put")
+	assert.True(t, put.Oneway, "put should be oneway")
+	assert.Nil(t, put.Returns, "put should return void")
+
+	assert.Contains(t, prog.functions(store), "health", "inherited functions")
+	assert.Equal(t, "binary", typeName(prog, get.Returns), "typedefs should be resolved")
+}
+
+func TestParseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idl")
+	require.NoError(t, err, "TempDir failed")
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "store.thrift"), []byte(testIDL), 0644))
+	_, err = ParseFile(filepath.Join(dir, "store.thrift"))
+	assert.Error(t, err, "ParseFile should fail with a missing include")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shared.thrift"), []byte(sharedIDL), 0644))
+	prog, err := ParseFile(filepath.Join(dir, "store.thrift"))
+	require.NoError(t, err, "ParseFile failed")
+	assert.Contains(t, prog.Services, "shared.Base", "included service")
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			files:   map[string]string{"a.thrift": `include "b.thrift"`},
+			wantErr: "missing IDL file b.thrift",
+		},
+		{
+			files:   map[string]string{"a.thrift": "struct S {\n 1: string"},
+			wantErr: "a.thrift: line 2: expected identifier, got end of file",
+		},
+		{
+			files:   map[string]string{"a.thrift": "service S {}\nfoo"},
+			wantErr: `a.thrift: line 2: unknown definition "foo"`,
+		},
+		{
+			files:   map[string]string{"a.thrift": "/* unterminated"},
+			wantErr: "a.thrift: line 1: unterminated comment",
+		},
+		{
+			files:   map[string]string{"a.thrift": `const string s = "unterminated`},
+			wantErr: "a.thrift: line 1: unterminated string",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFiles(tt.files, "a.thrift")
+		if assert.Error(t, err, "expected error for %v", tt.files) {
+			assert.Equal(t, tt.wantErr, err.Error(), "unexpected error for %v", tt.files)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package idl parses Thrift IDLs into a model of the services and types
+// they define, compares two IDLs for incompatible changes, and converts
+// between JSON and Thrift to call their functions.
+//
+// The parser only retains what affects compatibility on the wire, so
+// constants, namespaces, default values and annotations are skipped.
+package idl
+
+import "fmt"
+
+// Program is the set of definitions in a Thrift IDL and the IDLs it includes.
+// Definitions from included IDLs are qualified with the include's name,
+// e.g. "shared.Base".
+type Program struct {
+	Services map[string]*Service
+	Structs  map[string]*Struct
+	Enums    map[string]*Enum
+	Typedefs map[string]*Type
+}
+
+func newProgram() *Program {
+	return &Program{
+		Services: make(map[string]*Service),
+		Structs:  make(map[string]*Struct),
+		Enums:    make(map[string]*Enum),
+		Typedefs: make(map[string]*Type),
+	}
+}
+
+// Service is a Thrift service.
+type Service struct {
+	Name      string
+	Extends   string
+	Functions map[string]*Function
+}
+
+// Function is a method on a Thrift service.
+type Function struct {
+	Name    string
+	Oneway  bool
+	Returns *Type // nil for void
+	Args    []*Field
+	Throws  []*Field
+}
+
+// Struct is a Thrift struct, union or exception.
+type Struct struct {
+	Name   string
+	Kind   string
+	Fields []*Field
+}
+
+// Enum is a Thrift enum.
+type Enum struct {
+	Name   string
+	Values map[string]int
+}
+
+// Requiredness is whether a field is required, optional, or neither.
+type Requiredness int
+
+// Requiredness values for fields.
+const (
+	Default Requiredness = iota
+	Required
+	Optional
+)
+
+func (r Requiredness) String() string {
+	switch r {
+	case Required:
+		return "required"
+	case Optional:
+		return "optional"
+	default:
+		return "default"
+	}
+}
+
+// Field is a field of a struct, or an argument or exception of a function.
+type Field struct {
+	ID           int
+	Name         string
+	Type         *Type
+	Requiredness Requiredness
+}
+
+// Type is a reference to a base type, container, or named type.
+type Type struct {
+	// Name is the base type (e.g. "i32"), container ("list", "set", "map"),
+	// or the qualified name of a user-defined type.
+	Name string
+
+	// Key is the key type of a map.
+	Key *Type
+
+	// Value is the element type of a list or set, or the value type of a map.
+	Value *Type
+}
+
+func (t *Type) String() string {
+	switch t.Name {
+	case "map":
+		return fmt.Sprintf("map<%v, %v>", t.Key, t.Value)
+	case "list", "set":
+		return fmt.Sprintf("%v<%v>", t.Name, t.Value)
+	default:
+		return t.Name
+	}
+}
+
+var baseTypes = map[string]bool{
+	"bool":   true,
+	"byte":   true,
+	"i8":     true,
+	"i16":    true,
+	"i32":    true,
+	"i64":    true,
+	"double": true,
+	"string": true,
+	"binary": true,
+	"slist":  true,
+}
+
+// resolve returns the type with any typedefs replaced by their definitions.
+func (p *Program) resolve(t *Type) *Type {
+	if t == nil {
+		return nil
+	}
+	for seen := 0; seen < len(p.Typedefs); seen++ {
+		target, ok := p.Typedefs[t.Name]
+		if !ok {
+			break
+		}
+		t = target
+	}
+	if t.Key == nil && t.Value == nil {
+		return t
+	}
+	return &Type{Name: t.Name, Key: p.resolve(t.Key), Value: p.resolve(t.Value)}
+}
+
+// functions returns the functions of the service, including those inherited
+// from the services it extends.
+func (p *Program) functions(s *Service) map[string]*Function {
+	all := make(map[string]*Function)
+	for depth := 0; s != nil && depth <= len(p.Services); depth++ {
+		for name, f := range s.Functions {
+			if _, ok := all[name]; !ok {
+				all[name] = f
+			}
+		}
+		s = p.Services[s.Extends]
+	}
+	return all
+}
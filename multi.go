@@ -0,0 +1,202 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// peerList is a flag.Value that collects peers from repeated flags, where
+// each flag value may contain a comma-separated list of peers.
+type peerList []string
+
+func (l *peerList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *peerList) Set(v string) error {
+	for _, peer := range strings.Split(v, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			*l = append(*l, peer)
+		}
+	}
+	return nil
+}
+
+type requireKind int
+
+const (
+	requireAll requireKind = iota
+	requireAny
+	requireQuorum
+	requirePercent
+)
+
+// requirement is a flag.Value that specifies how many peers must be healthy
+// for a multi-peer health check to pass.
+type requirement struct {
+	kind    requireKind
+	percent int
+}
+
+func (r *requirement) String() string {
+	switch r.kind {
+	case requireAny:
+		return "any"
+	case requireQuorum:
+		return "quorum"
+	case requirePercent:
+		return strconv.Itoa(r.percent) + "%"
+	default:
+		return "all"
+	}
+}
+
+func (r *requirement) Set(v string) error {
+	switch v {
+	case "all":
+		*r = requirement{kind: requireAll}
+	case "any":
+		*r = requirement{kind: requireAny}
+	case "quorum":
+		*r = requirement{kind: requireQuorum}
+	default:
+		if !strings.HasSuffix(v, "%") {
+			return fmt.Errorf("unknown requirement %q, must be all, any, quorum or N%%", v)
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+		if err != nil || percent <= 0 || percent > 100 {
+			return fmt.Errorf("invalid percentage %q, must be between 1%% and 100%%", v)
+		}
+		*r = requirement{kind: requirePercent, percent: percent}
+	}
+	return nil
+}
+
+// needed returns the number of healthy peers required out of total.
+func (r requirement) needed(total int) int {
+	switch r.kind {
+	case requireAny:
+		return 1
+	case requireQuorum:
+		return total/2 + 1
+	case requirePercent:
+		// Round up so that a percentage is never satisfied by fewer peers.
+		return (r.percent*total + 99) / 100
+	default:
+		return total
+	}
+}
+
+// healthCheckPeers checks the service on all peers concurrently using a
+// single channel, with at most concurrency checks in flight. The results are
+// returned in the same order as peers, and an exitError is returned if fewer
+// peers than required are healthy.
+func healthCheckPeers(peers []string, opts checkOptions, concurrency int, req requirement) ([]checkResult, error) {
+	if len(peers) == 0 {
+		return nil, exitError{_exitUsage, "Must specify peers to health check"}
+	}
+	if err := validateArgs(opts); err != nil {
+		return nil, err
+	}
+	if concurrency <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive concurrency"}
+	}
+
+	ch, err := newChannel(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	hc, err := newHealthChecker(ch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]checkResult, len(peers))
+	forEachPeer(peers, concurrency, func(i int, peer string) {
+		results[i] = checkTarget(hc, peer, opts)
+	})
+	return results, checkRequirement(opts.serviceName, results, req)
+}
+
+// forEachPeer calls fn for each peer, with at most concurrency calls in
+// flight, and returns once all calls have completed.
+func forEachPeer(peers []string, concurrency int, fn func(i int, peer string)) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i, peer := range peers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, peer string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fn(i, peer)
+		}(i, peer)
+	}
+	wg.Wait()
+}
+
+// countHealthy returns the number of peers that are healthy.
+func countHealthy(results []checkResult) int {
+	var healthy int
+	for _, r := range results {
+		if r.exitErr() == nil {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// checkRequirement returns an exitError if fewer peers than required are
+// healthy. If all unhealthy peers failed in the same way, such as explicitly
+// reporting themselves unhealthy or timing out, the exit code reflects that.
+func checkRequirement(serviceName string, results []checkResult, req requirement) error {
+	code := 0
+	for _, r := range results {
+		err := r.exitErr()
+		if err == nil {
+			continue
+		}
+
+		switch peerCode := getExitCode(err); {
+		case code == 0:
+			code = peerCode
+		case code != peerCode:
+			code = _exitUnknownUnhealthy
+		}
+	}
+
+	healthy, needed := countHealthy(results), req.needed(len(results))
+	if healthy >= needed {
+		return nil
+	}
+
+	return exitError{code, fmt.Sprintf("NOT OK %v\n%v of %v peers healthy, require %v (%v)\n",
+		serviceName, healthy, len(results), needed, req.String())}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerList(t *testing.T) {
+	var l peerList
+	require.NoError(t, l.Set("1.1.1.1:1"))
+	require.NoError(t, l.Set("1.1.1.1:2, 1.1.1.1:3,"))
+	assert.Equal(t, peerList{"1.1.1.1:1", "1.1.1.1:2", "1.1.1.1:3"}, l)
+	assert.Equal(t, "1.1.1.1:1,1.1.1.1:2,1.1.1.1:3", l.String())
+}
+
+func TestRequirement(t *testing.T) {
+	tests := []struct {
+		value   string
+		total   int
+		want    int
+		wantErr bool
+	}{
+		{value: "all", total: 5, want: 5},
+		{value: "any", total: 5, want: 1},
+		{value: "quorum", total: 5, want: 3},
+		{value: "quorum", total: 4, want: 3},
+		{value: "50%", total: 5, want: 3},
+		{value: "100%", total: 3, want: 3},
+		{value: "1%", total: 3, want: 1},
+		{value: "0%", wantErr: true},
+		{value: "101%", wantErr: true},
+		{value: "abc%", wantErr: true},
+		{value: "most", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var r requirement
+		err := r.Set(tt.value)
+		if tt.wantErr {
+			assert.Error(t, err, "Set(%q) should fail", tt.value)
+			continue
+		}
+
+		require.NoError(t, err, "Set(%q) failed", tt.value)
+		assert.Equal(t, tt.value, r.String(), "Unexpected String for %q", tt.value)
+		assert.Equal(t, tt.want, r.needed(tt.total), "Unexpected needed(%v) for %q", tt.total, tt.value)
+	}
+}
+
+func TestHealthCheckPeers(t *testing.T) {
+	healthy := setupServer(t, healthOk)
+	defer healthy.Close()
+
+	unhealthy := setupServer(t, healthNotOk)
+	defer unhealthy.Close()
+
+	noHandler := setupServer(t, nil)
+	defer noHandler.Close()
+
+	tests := []struct {
+		msg         string
+		peers       []string
+		require     string
+		concurrency int
+		wantExit    int
+	}{
+		{
+			msg:      "no peers",
+			wantExit: _exitUsage,
+		},
+		{
+			msg:         "invalid concurrency",
+			peers:       []string{healthy.PeerInfo().HostPort},
+			concurrency: -1,
+			wantExit:    _exitUsage,
+		},
+		{
+			msg:   "all healthy",
+			peers: []string{healthy.PeerInfo().HostPort, healthy.PeerInfo().HostPort},
+		},
+		{
+			msg:      "all required, one explicitly unhealthy",
+			peers:    []string{healthy.PeerInfo().HostPort, unhealthy.PeerInfo().HostPort},
+			wantExit: _exitExplicitUnhealthy,
+		},
+		{
+			msg:      "all required, one error",
+			peers:    []string{healthy.PeerInfo().HostPort, unhealthy.PeerInfo().HostPort, noHandler.PeerInfo().HostPort},
+			wantExit: _exitUnknownUnhealthy,
+		},
+		{
+			msg:      "all required, all failing with the same error",
+			peers:    []string{healthy.PeerInfo().HostPort, noHandler.PeerInfo().HostPort, noHandler.PeerInfo().HostPort},
+			wantExit: _exitBadRequest,
+		},
+		{
+			msg:     "any required",
+			peers:   []string{unhealthy.PeerInfo().HostPort, noHandler.PeerInfo().HostPort, healthy.PeerInfo().HostPort},
+			require: "any",
+		},
+		{
+			msg:      "quorum required",
+			peers:    []string{unhealthy.PeerInfo().HostPort, noHandler.PeerInfo().HostPort, healthy.PeerInfo().HostPort},
+			require:  "quorum",
+			wantExit: _exitUnknownUnhealthy,
+		},
+		{
+			msg:         "percentage required with concurrency 1",
+			peers:       []string{unhealthy.PeerInfo().HostPort, healthy.PeerInfo().HostPort, healthy.PeerInfo().HostPort},
+			require:     "60%",
+			concurrency: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			req := requirement{kind: requireAll}
+			if tt.require != "" {
+				require.NoError(t, req.Set(tt.require), "Failed to parse requirement")
+			}
+			concurrency := 10
+			if tt.concurrency != 0 {
+				concurrency = tt.concurrency
+			}
+
+			results, err := healthCheckPeers(tt.peers, checkOptions{serviceName: "svc", timeout: time.Second}, concurrency, req)
+			if tt.wantExit > 0 {
+				require.Error(t, err, "Expected health check to fail")
+				assert.Equal(t, tt.wantExit, getExitCode(err), "Unexpected exit code")
+			} else {
+				require.NoError(t, err, "Expected health check to pass")
+			}
+			if tt.wantExit == _exitUsage {
+				return
+			}
+
+			require.Len(t, results, len(tt.peers), "Expected a result per peer")
+			for i, r := range results {
+				assert.Equal(t, tt.peers[i], r.Peer, "Results should be in peer order")
+			}
+		})
+	}
+}
+
+func TestPeerResultString(t *testing.T) {
+	healthy := setupServer(t, healthOk)
+	defer healthy.Close()
+
+	unhealthy := setupServer(t, healthNotOk)
+	defer unhealthy.Close()
+
+	peers := []string{healthy.PeerInfo().HostPort, unhealthy.PeerInfo().HostPort}
+	results, err := healthCheckPeers(peers, checkOptions{serviceName: "svc", timeout: time.Second}, 1, requirement{kind: requireAny})
+	require.NoError(t, err, "Expected any requirement to pass")
+
+	assert.Equal(t, "OK "+peers[0], results[0].String())
+	assert.Equal(t, "NOT OK "+peers[1]+" hello world", results[1].String())
+}
+
+func TestIntegrationMultiplePeers(t *testing.T) {
+	healthy := setupServer(t, healthOk)
+	defer healthy.Close()
+
+	unhealthy := setupServer(t, healthNotOk)
+	defer unhealthy.Close()
+
+	setArgs(
+		"--peer", healthy.PeerInfo().HostPort+","+unhealthy.PeerInfo().HostPort,
+		"--peer", healthy.PeerInfo().HostPort,
+		"--serviceName", "svc",
+		"--require", "quorum",
+	)
+	main()
+	assert.Len(t, peers, 3, "Expected peers from repeated and comma-separated flags")
+}
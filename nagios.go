@@ -0,0 +1,212 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/tcheck/health"
+)
+
+// Exit codes used by Nagios plugins.
+const (
+	_nagiosOK       = 0
+	_nagiosWarning  = 1
+	_nagiosCritical = 2
+	_nagiosUnknown  = 3
+)
+
+var _nagiosStates = map[int]string{
+	_nagiosOK:       "OK",
+	_nagiosWarning:  "WARNING",
+	_nagiosCritical: "CRITICAL",
+	_nagiosUnknown:  "UNKNOWN",
+}
+
+// nagiosThresholds are the latencies at which a healthy result is a warning
+// or critical. A zero threshold is not checked.
+type nagiosThresholds struct {
+	warning  time.Duration
+	critical time.Duration
+}
+
+func (t nagiosThresholds) validate() error {
+	if t.warning < 0 || t.critical < 0 {
+		return exitError{_exitUsage, "Must specify non-negative latency thresholds"}
+	}
+	if t.warning > 0 && t.critical > 0 && t.warning > t.critical {
+		return exitError{_exitUsage, "Must specify a warning threshold that's no more than the critical threshold"}
+	}
+	return nil
+}
+
+// state returns the Nagios state for a healthy result with the latency.
+func (t nagiosThresholds) state(latency time.Duration) int {
+	switch {
+	case t.critical > 0 && latency >= t.critical:
+		return _nagiosCritical
+	case t.warning > 0 && latency >= t.warning:
+		return _nagiosWarning
+	}
+	return _nagiosOK
+}
+
+// perfdata returns the performance data for the latency of a health check.
+func (t nagiosThresholds) perfdata(label string, latency time.Duration) string {
+	return fmt.Sprintf("%v=%vms;%v;%v;0", label, formatMs(latency), formatThreshold(t.warning), formatThreshold(t.critical))
+}
+
+func formatThreshold(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return formatMs(d)
+}
+
+// formatMs formats a duration as milliseconds, to microsecond precision.
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d/time.Microsecond)/1000, 'f', -1, 64)
+}
+
+// nagiosStatus is the outcome of a health check for a Nagios plugin.
+type nagiosStatus struct {
+	code     int
+	summary  string
+	perfdata []string
+}
+
+// String returns the status line, with the performance data, if any, after
+// a "|" as Nagios expects.
+func (s nagiosStatus) String() string {
+	line := fmt.Sprintf("TCHECK %v - %v", _nagiosStates[s.code], s.summary)
+	if len(s.perfdata) > 0 {
+		line += " | " + strings.Join(s.perfdata, " ")
+	}
+	return line
+}
+
+// unknownStatus returns the status when a health check couldn't be made.
+func unknownStatus(err error) nagiosStatus {
+	return nagiosStatus{code: _nagiosUnknown, summary: singleLine(err.Error())}
+}
+
+// singleLine joins the lines of a message, since Nagios only shows the first
+// line of output in most views.
+func singleLine(msg string) string {
+	return strings.Join(strings.Fields(strings.Replace(msg, "\n", " ", -1)), " ")
+}
+
+// newNagiosStatus returns the status for the result of healthCheck.
+func newNagiosStatus(r checkResult, err error, t nagiosThresholds) nagiosStatus {
+	if err != nil && len(r.Attempts) == 0 {
+		return unknownStatus(err)
+	}
+
+	s := nagiosStatus{perfdata: []string{t.perfdata("time", r.Latency)}}
+	switch {
+	case r.err != nil:
+		s.code = _nagiosCritical
+		s.summary = fmt.Sprintf("%v %v: %v", nagiosTarget(r), health.Classify(r.err), r.err)
+	case !r.Healthy:
+		s.code = _nagiosCritical
+		s.summary = fmt.Sprintf("%v unhealthy: %v", nagiosTarget(r), r.Message)
+	default:
+		s.code = t.state(r.Latency)
+		s.summary = fmt.Sprintf("%v healthy in %vms", nagiosTarget(r), formatMs(r.Latency))
+	}
+	return s
+}
+
+// nagiosTarget describes the service and where the health check was sent.
+func nagiosTarget(r checkResult) string {
+	if r.Peer != "" {
+		return fmt.Sprintf("%v at %v", r.ServiceName, r.Peer)
+	}
+	return r.target()
+}
+
+// newNagiosMultiStatus returns the status for the results of
+// healthCheckPeers. The latency thresholds apply to the slowest healthy peer.
+func newNagiosMultiStatus(serviceName string, results []checkResult, err error, t nagiosThresholds) nagiosStatus {
+	if len(results) == 0 {
+		return unknownStatus(err)
+	}
+
+	var (
+		s       nagiosStatus
+		healthy int
+		slowest time.Duration
+	)
+	for _, r := range results {
+		s.perfdata = append(s.perfdata, t.perfdata(fmt.Sprintf("'time_%v'", r.target()), r.Latency))
+		if r.exitErr() == nil {
+			healthy++
+			if r.Latency > slowest {
+				slowest = r.Latency
+			}
+		}
+	}
+
+	if err != nil {
+		s.code = _nagiosCritical
+	} else {
+		s.code = t.state(slowest)
+	}
+	s.summary = fmt.Sprintf("%v %v of %v peers healthy", serviceName, healthy, len(results))
+	if healthy > 0 {
+		s.summary += fmt.Sprintf(", slowest healthy in %vms", formatMs(slowest))
+	}
+	return s
+}
+
+func runNagios(p printer, opts checkOptions) {
+	s := nagiosCheck(opts, nagiosThresholds{warning: *warning, critical: *critical})
+	fmt.Fprintln(p.w, s)
+	if s.code != _nagiosOK {
+		_osExit(s.code)
+	}
+}
+
+// nagiosCheck health checks the peers, or the service through Hyperbahn if
+// there are none, and returns the status for a Nagios plugin.
+func nagiosCheck(opts checkOptions, t nagiosThresholds) nagiosStatus {
+	if *watchMode || waitUntil != waitNone {
+		return unknownStatus(exitError{_exitUsage, "Can't use --format nagios with --watch or --waitFor"})
+	}
+	if err := t.validate(); err != nil {
+		return unknownStatus(err)
+	}
+
+	if len(peers) > 1 {
+		results, err := healthCheckPeers(peers, opts, *concurrency, requirePeers)
+		return newNagiosMultiStatus(opts.serviceName, results, err, t)
+	}
+
+	var peer string
+	if len(peers) == 1 {
+		peer = peers[0]
+	}
+	result, err := healthCheck(peer, *hostsFile, opts)
+	return newNagiosStatus(result, err, t)
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/health"
+	"github.com/uber/tcheck/tchecktest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func nagiosResult(latency time.Duration, healthy bool, message string, err error) checkResult {
+	return checkResult{
+		Result: health.Result{
+			Peer:        "127.0.0.1:4532",
+			ServiceName: "svc",
+			Healthy:     healthy,
+			Message:     message,
+			Latency:     latency,
+			Attempts:    []health.Attempt{{Err: err, Latency: latency}},
+		},
+		err: err,
+	}
+}
+
+func TestNagiosStatus(t *testing.T) {
+	thresholds := nagiosThresholds{warning: 50 * time.Millisecond, critical: 200 * time.Millisecond}
+
+	tests := []struct {
+		msg        string
+		result     checkResult
+		err        error
+		thresholds nagiosThresholds
+		want       string
+		wantCode   int
+	}{
+		{
+			msg:        "healthy",
+			result:     nagiosResult(12*time.Millisecond, true, "", nil),
+			thresholds: thresholds,
+			want:       "TCHECK OK - svc at 127.0.0.1:4532 healthy in 12ms | time=12ms;50;200;0",
+			wantCode:   _nagiosOK,
+		},
+		{
+			msg:      "healthy without thresholds",
+			result:   nagiosResult(1234*time.Microsecond, true, "", nil),
+			want:     "TCHECK OK - svc at 127.0.0.1:4532 healthy in 1.234ms | time=1.234ms;;;0",
+			wantCode: _nagiosOK,
+		},
+		{
+			msg:        "slow",
+			result:     nagiosResult(50*time.Millisecond, true, "", nil),
+			thresholds: thresholds,
+			want:       "TCHECK WARNING - svc at 127.0.0.1:4532 healthy in 50ms | time=50ms;50;200;0",
+			wantCode:   _nagiosWarning,
+		},
+		{
+			msg:        "too slow",
+			result:     nagiosResult(250*time.Millisecond, true, "", nil),
+			thresholds: thresholds,
+			want:       "TCHECK CRITICAL - svc at 127.0.0.1:4532 healthy in 250ms | time=250ms;50;200;0",
+			wantCode:   _nagiosCritical,
+		},
+		{
+			msg:        "unhealthy",
+			result:     nagiosResult(time.Millisecond, false, "draining", nil),
+			err:        exitError{_exitExplicitUnhealthy, "NOT OK draining\n"},
+			thresholds: thresholds,
+			want:       "TCHECK CRITICAL - svc at 127.0.0.1:4532 unhealthy: draining | time=1ms;50;200;0",
+			wantCode:   _nagiosCritical,
+		},
+		{
+			msg:        "error",
+			result:     nagiosResult(time.Second, false, "", tchannel.ErrTimeout),
+			err:        exitError{_exitTimeout, "NOT OK svc\nError: timeout\n"},
+			thresholds: thresholds,
+			want:       "TCHECK CRITICAL - svc at 127.0.0.1:4532 timeout: " + tchannel.ErrTimeout.Error() + " | time=1000ms;50;200;0",
+			wantCode:   _nagiosCritical,
+		},
+		{
+			msg:      "usage",
+			result:   checkResult{},
+			err:      exitError{_exitUsage, "Must specify a service name\nfor the destination"},
+			want:     "TCHECK UNKNOWN - Must specify a service name for the destination",
+			wantCode: _nagiosUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		s := newNagiosStatus(tt.result, tt.err, tt.thresholds)
+		assert.Equal(t, tt.want, s.String(), "%v: unexpected status line", tt.msg)
+		assert.Equal(t, tt.wantCode, s.code, "%v: unexpected code", tt.msg)
+	}
+}
+
+func TestNagiosMultiStatus(t *testing.T) {
+	thresholds := nagiosThresholds{warning: 50 * time.Millisecond}
+	results := []checkResult{
+		nagiosResult(10*time.Millisecond, true, "", nil),
+		nagiosResult(60*time.Millisecond, true, "", nil),
+		nagiosResult(time.Millisecond, false, "draining", nil),
+	}
+	results[1].Peer = "127.0.0.1:4533"
+
+	s := newNagiosMultiStatus("svc", results, nil, thresholds)
+	assert.Equal(t, _nagiosWarning, s.code, "Slowest healthy peer should be a warning")
+	assert.Equal(t, "TCHECK WARNING - svc 2 of 3 peers healthy, slowest healthy in 60ms | "+
+		"'time_127.0.0.1:4532'=10ms;50;;0 'time_127.0.0.1:4533'=60ms;50;;0 'time_127.0.0.1:4532'=1ms;50;;0", s.String())
+
+	s = newNagiosMultiStatus("svc", results, errors.New("not enough healthy peers"), thresholds)
+	assert.Equal(t, _nagiosCritical, s.code, "Failed requirement should be critical")
+
+	s = newNagiosMultiStatus("svc", results[2:], errors.New("not enough healthy peers"), thresholds)
+	assert.Equal(t, "svc 0 of 1 peers healthy", s.summary, "Unexpected summary without healthy peers")
+
+	s = newNagiosMultiStatus("svc", nil, exitError{_exitUsage, "Must specify a positive concurrency"}, thresholds)
+	assert.Equal(t, _nagiosUnknown, s.code, "Usage error should be unknown")
+}
+
+func TestNagiosThresholds(t *testing.T) {
+	assert.NoError(t, nagiosThresholds{}.validate(), "No thresholds should be valid")
+	assert.NoError(t, nagiosThresholds{warning: time.Second}.validate(), "Only a warning should be valid")
+	assert.Error(t, nagiosThresholds{warning: -1}.validate(), "Negative threshold should be invalid")
+	assert.Error(t, nagiosThresholds{warning: 2, critical: 1}.validate(), "Warning above critical should be invalid")
+}
+
+func TestIntegrationNagios(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	healthy := tchecktest.NewServer(t, "svc")
+	defer healthy.Close()
+
+	slow := tchecktest.NewServer(t, "svc")
+	defer slow.Close()
+	slow.SetLatency(20 * time.Millisecond)
+
+	unhealthy := tchecktest.NewServer(t, "svc")
+	defer unhealthy.Close()
+	unhealthy.SetResponse(tchecktest.Unhealthy("draining"))
+
+	tests := []struct {
+		args     []string
+		wantExit int
+	}{
+		{
+			args:     []string{"--peer", healthy.HostPort(), "--serviceName", "svc"},
+			wantExit: _nagiosOK,
+		},
+		{
+			args:     []string{"--peer", slow.HostPort(), "--serviceName", "svc", "--warning", "10ms"},
+			wantExit: _nagiosWarning,
+		},
+		{
+			args:     []string{"--peer", slow.HostPort(), "--serviceName", "svc", "--warning", "5ms", "--critical", "10ms"},
+			wantExit: _nagiosCritical,
+		},
+		{
+			args:     []string{"--peer", unhealthy.HostPort(), "--serviceName", "svc"},
+			wantExit: _nagiosCritical,
+		},
+		{
+			args:     []string{"--peer", healthy.HostPort() + "," + unhealthy.HostPort(), "--serviceName", "svc", "--require", "any"},
+			wantExit: _nagiosOK,
+		},
+		{
+			args:     []string{"--peer", healthy.HostPort()},
+			wantExit: _nagiosUnknown,
+		},
+		{
+			args:     []string{"--peer", healthy.HostPort(), "--serviceName", "svc", "--watch"},
+			wantExit: _nagiosUnknown,
+		},
+		{
+			args:     []string{"--peer", healthy.HostPort(), "--serviceName", "svc", "--warning", "2s", "--critical", "1s"},
+			wantExit: _nagiosUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			setArgs(append(tt.args, "--format", "nagios")...)
+			main()
+		}()
+
+		<-done
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for %v", tt.args)
+	}
+}
+
+func TestFormatFlag(t *testing.T) {
+	for _, name := range []string{"output", "format"} {
+		setArgs("--"+name, "nagios")
+		require.NoError(t, flag.CommandLine.Parse(os.Args[1:]), "Failed to parse --%v", name)
+		assert.Equal(t, outputNagios, output, "Unexpected output format for --%v", name)
+	}
+	setArgs()
+}
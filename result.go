@@ -0,0 +1,418 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/uber/tcheck/health"
+)
+
+// checkResult is the result of health checking a single peer.
+type checkResult struct {
+	health.Result
+
+	// err is the error if the health check failed. It's nil if the peer
+	// responded, even if it reported itself unhealthy.
+	err error
+
+	// timed is set if the time taken to connect to the peer was measured.
+	timed bool
+}
+
+// exitErr converts the result of a Meta::health call to an exitError
+// if the service is not healthy.
+func (r checkResult) exitErr() error {
+	if r.err != nil {
+		return exitError{errExitCode(r.err), fmt.Sprintf("NOT OK %v\nError: %v\n", r.ServiceName, r.err)}
+	}
+	if !r.Healthy {
+		return exitError{_exitExplicitUnhealthy, fmt.Sprintf("NOT OK %v\n", r.Message)}
+	}
+	return nil
+}
+
+// target returns a description of where the health check was sent.
+func (r checkResult) target() string {
+	if r.Peer != "" {
+		return r.Peer
+	}
+	return fmt.Sprintf("%v via %v", r.ServiceName, r.Relay)
+}
+
+// timing returns the time taken by each phase of the last attempt, and
+// whether it was measured. Only the call is timed for health checks routed
+// through Hyperbahn.
+func (r checkResult) timing() (health.Timing, bool) {
+	if len(r.Attempts) == 0 {
+		return health.Timing{}, false
+	}
+	return r.Attempts[len(r.Attempts)-1].Timing, r.timed
+}
+
+// String returns a single line summary of the result, used when
+// printing the results for multiple peers.
+func (r checkResult) String() string {
+	var s string
+	switch {
+	case r.err != nil:
+		s = fmt.Sprintf("NOT OK %v Error: %v", r.target(), r.err)
+	case !r.Healthy:
+		s = fmt.Sprintf("NOT OK %v %v", r.target(), r.Message)
+	default:
+		s = fmt.Sprintf("OK %v", r.target())
+	}
+	if len(r.Attempts) > 1 {
+		s += fmt.Sprintf(" (after %v attempts)", len(r.Attempts))
+	}
+	return s
+}
+
+type outputFormat int
+
+const (
+	outputText outputFormat = iota
+	outputJSON
+	outputNagios
+)
+
+func (f *outputFormat) String() string {
+	switch *f {
+	case outputJSON:
+		return "json"
+	case outputNagios:
+		return "nagios"
+	}
+	return "text"
+}
+
+func (f *outputFormat) Set(v string) error {
+	switch v {
+	case "text":
+		*f = outputText
+	case "json":
+		*f = outputJSON
+	case "nagios":
+		*f = outputNagios
+	default:
+		return fmt.Errorf("unknown output format %q, must be text, json or nagios", v)
+	}
+	return nil
+}
+
+// jsonResult is the record written for each health check with --output json.
+type jsonResult struct {
+	Peer         string  `json:"peer,omitempty"`
+	ResolvedPeer string  `json:"resolvedPeer,omitempty"`
+	Relay        string  `json:"relay,omitempty"`
+	Service      string  `json:"service"`
+	OK           bool    `json:"ok"`
+	Message      string  `json:"message,omitempty"`
+	Error        string  `json:"error,omitempty"`
+	ErrorClass   string  `json:"errorClass,omitempty"`
+	ExitCode     int     `json:"exitCode"`
+	LatencyMs    float64 `json:"latencyMs"`
+
+	HealthTypeIgnored bool `json:"healthTypeIgnored,omitempty"`
+
+	// Identity is only set if the health check connected to the peer.
+	Identity *jsonIdentity `json:"identity,omitempty"`
+
+	// Timing is only set if the time taken to connect was measured.
+	Timing *jsonTiming `json:"timing,omitempty"`
+
+	// Attempts are only set if the health check was retried.
+	Attempts []jsonAttempt `json:"attempts,omitempty"`
+}
+
+// jsonSummary is the record written after the record for each peer with
+// --output json when checking multiple peers, with the verdict of --require.
+type jsonSummary struct {
+	Summary    bool   `json:"summary"`
+	Service    string `json:"service"`
+	OK         bool   `json:"ok"`
+	Healthy    int    `json:"healthy"`
+	Total      int    `json:"total"`
+	Required   int    `json:"required"`
+	Require    string `json:"require"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	ExitCode   int    `json:"exitCode"`
+}
+
+func newJSONSummary(results []checkResult, req requirement, err error) jsonSummary {
+	js := jsonSummary{
+		Summary:  true,
+		Service:  results[0].ServiceName,
+		OK:       err == nil,
+		Healthy:  countHealthy(results),
+		Total:    len(results),
+		Required: req.needed(len(results)),
+		Require:  req.String(),
+	}
+	if err != nil {
+		js.ExitCode = getExitCode(err)
+		js.ErrorClass = errorClass(js.ExitCode)
+	}
+	return js
+}
+
+// jsonAttempt is the outcome of a single attempt of a health check.
+type jsonAttempt struct {
+	Error      string  `json:"error,omitempty"`
+	ErrorClass string  `json:"errorClass,omitempty"`
+	LatencyMs  float64 `json:"latencyMs"`
+}
+
+// jsonIdentity is how the peer identified itself when connecting.
+type jsonIdentity struct {
+	HostPort        string `json:"hostPort"`
+	ProcessName     string `json:"processName"`
+	Language        string `json:"language,omitempty"`
+	LanguageVersion string `json:"languageVersion,omitempty"`
+	TChannelVersion string `json:"tchannelVersion,omitempty"`
+}
+
+// jsonTiming is the time taken by each phase of the last attempt.
+type jsonTiming struct {
+	DNSMs       float64 `json:"dnsMs"`
+	ConnectMs   float64 `json:"connectMs"`
+	HandshakeMs float64 `json:"handshakeMs"`
+	CallMs      float64 `json:"callMs,omitempty"`
+	PingMs      float64 `json:"pingMs,omitempty"`
+	TotalMs     float64 `json:"totalMs"`
+}
+
+func newJSONResult(r checkResult, err error) jsonResult {
+	jr := jsonResult{
+		Peer:         r.Peer,
+		ResolvedPeer: r.ResolvedPeer,
+		Relay:        r.Relay,
+		Service:      r.ServiceName,
+		OK:           err == nil,
+		Message:      r.Message,
+		LatencyMs:    r.Latency.Seconds() * 1000,
+
+		HealthTypeIgnored: r.HealthTypeIgnored,
+	}
+
+	switch {
+	case r.err != nil:
+		jr.Error = r.err.Error()
+	case err != nil && len(r.Attempts) == 0:
+		// The health check wasn't made, so report the reason instead.
+		jr.Error = err.Error()
+	}
+	if err != nil {
+		jr.ExitCode = getExitCode(err)
+		jr.ErrorClass = errorClass(jr.ExitCode)
+	}
+	if id := r.Identity; id.ProcessName != "" {
+		jr.Identity = &jsonIdentity{
+			HostPort:        id.HostPort,
+			ProcessName:     id.ProcessName,
+			Language:        id.Language,
+			LanguageVersion: id.LanguageVersion,
+			TChannelVersion: id.TChannelVersion,
+		}
+	}
+	if t, ok := r.timing(); ok {
+		jr.Timing = &jsonTiming{
+			DNSMs:       t.DNS.Seconds() * 1000,
+			ConnectMs:   t.Connect.Seconds() * 1000,
+			HandshakeMs: t.Handshake.Seconds() * 1000,
+			CallMs:      t.Call.Seconds() * 1000,
+			PingMs:      t.Ping.Seconds() * 1000,
+			TotalMs:     t.Total().Seconds() * 1000,
+		}
+	}
+	if len(r.Attempts) > 1 {
+		for _, a := range r.Attempts {
+			ja := jsonAttempt{LatencyMs: a.Latency.Seconds() * 1000}
+			if a.Err != nil {
+				ja.Error = a.Err.Error()
+				ja.ErrorClass = string(health.Classify(a.Err))
+			}
+			jr.Attempts = append(jr.Attempts, ja)
+		}
+	}
+	return jr
+}
+
+// _classExitCodes are the exit codes for each class of failed call.
+var _classExitCodes = map[health.ErrorClass]int{
+	health.ClassUnknown:           _exitUnknownUnhealthy,
+	health.ClassConnectionRefused: _exitConnectionRefused,
+	health.ClassDNS:               _exitDNSFailure,
+	health.ClassTimeout:           _exitTimeout,
+	health.ClassBusy:              _exitBusy,
+	health.ClassDeclined:          _exitDeclined,
+	health.ClassBadRequest:        _exitBadRequest,
+	health.ClassNetwork:           _exitNetworkError,
+	health.ClassProtocol:          _exitProtocolError,
+	health.ClassSlow:              _exitSlow,
+	health.ClassWrongProcess:      _exitWrongProcess,
+}
+
+// errExitCode returns the exit code for an error returned by a call.
+func errExitCode(err error) int {
+	if code, ok := _classExitCodes[health.Classify(err)]; ok {
+		return code
+	}
+	return _exitUnknownUnhealthy
+}
+
+// errorClass returns a label for the class of failure for an exit code.
+func errorClass(exitCode int) string {
+	switch exitCode {
+	case _exitUsage:
+		return "usage"
+	case _exitExplicitUnhealthy:
+		return "unhealthy"
+	case _exitIDLBreaking:
+		return "breaking"
+	case _exitWaitTimeout:
+		return "wait-timeout"
+	case _exitCallException:
+		return "exception"
+	case _exitAssertionFailed:
+		return "assertion"
+	}
+
+	for class, code := range _classExitCodes {
+		if code == exitCode {
+			return string(class)
+		}
+	}
+	return "unknown"
+}
+
+// printer writes health check results in the configured output format.
+type printer struct {
+	w      io.Writer
+	format outputFormat
+}
+
+func newPrinter(w io.Writer, format outputFormat) printer {
+	return printer{w: w, format: format}
+}
+
+// printResult prints the result of a single health check, where err is the
+// error returned by the health check.
+func (p printer) printResult(r checkResult, err error) {
+	if p.format == outputJSON {
+		p.writeJSON(newJSONResult(r, err))
+		return
+	}
+
+	if r.Relay != "" {
+		fmt.Fprintln(p.w, "Relay:", r.Relay)
+	}
+	p.printIdentity(r)
+	if r.HealthTypeIgnored {
+		fmt.Fprintln(p.w, "Peer does not support health check types, made a process health check")
+	}
+	if len(r.Attempts) > 1 {
+		// The outcome of the last attempt is the result.
+		for i, a := range r.Attempts[:len(r.Attempts)-1] {
+			fmt.Fprintf(p.w, "Attempt %v failed after %v: %v\n", i+1, a.Latency, a.Err)
+		}
+	}
+	p.printTiming(r)
+	p.printErr(err)
+}
+
+// printResults prints the results of health checking multiple peers, where
+// err is the aggregate error returned by the health check for req.
+func (p printer) printResults(results []checkResult, req requirement, err error) {
+	if p.format == outputJSON {
+		for _, r := range results {
+			p.writeJSON(newJSONResult(r, r.exitErr()))
+		}
+		if len(results) == 0 {
+			p.writeJSON(newJSONResult(checkResult{}, err))
+			return
+		}
+		p.writeJSON(newJSONSummary(results, req, err))
+		return
+	}
+
+	for _, r := range results {
+		fmt.Fprintln(p.w, r)
+		p.printIdentity(r)
+		p.printTiming(r)
+	}
+	p.printErr(err)
+}
+
+// printIdentity prints how the peer identified itself, if it was connected to.
+func (p printer) printIdentity(r checkResult) {
+	id := r.Identity
+	if id.ProcessName == "" {
+		return
+	}
+	fmt.Fprintf(p.w, "Process: %v on %v, %v %v tchannel %v\n",
+		id.ProcessName, id.HostPort, id.Language, id.LanguageVersion, id.TChannelVersion)
+}
+
+// printTiming prints the time taken by each phase of the last attempt, like
+// curl's --write-out, if it was measured.
+func (p printer) printTiming(r checkResult) {
+	t, ok := r.timing()
+	if !ok {
+		return
+	}
+
+	// Only one of the call or ping is made, so the other is omitted.
+	phases := []struct {
+		name     string
+		d        time.Duration
+		optional bool
+	}{
+		{"dns", t.DNS, false},
+		{"connect", t.Connect, false},
+		{"handshake", t.Handshake, false},
+		{"call", t.Call, true},
+		{"ping", t.Ping, true},
+		{"total", t.Total(), false},
+	}
+	for _, phase := range phases {
+		if phase.optional && phase.d == 0 {
+			continue
+		}
+		fmt.Fprintf(p.w, "  %-10v %v\n", phase.name+":", phase.d)
+	}
+}
+
+func (p printer) printErr(err error) {
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+		return
+	}
+	fmt.Fprintln(p.w, "OK")
+}
+
+func (p printer) writeJSON(v interface{}) {
+	// Encoding a struct of strings and numbers cannot fail.
+	json.NewEncoder(p.w).Encode(v)
+}
@@ -0,0 +1,255 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+func TestOutputFormat(t *testing.T) {
+	var f outputFormat
+	require.NoError(t, f.Set("json"))
+	assert.Equal(t, outputJSON, f)
+	assert.Equal(t, "json", f.String())
+
+	require.NoError(t, f.Set("text"))
+	assert.Equal(t, outputText, f)
+	assert.Equal(t, "text", f.String())
+
+	require.NoError(t, f.Set("nagios"))
+	assert.Equal(t, outputNagios, f)
+	assert.Equal(t, "nagios", f.String())
+
+	assert.Error(t, f.Set("xml"), "Expected unknown format to fail")
+}
+
+func decodeJSONResults(t *testing.T, buf *bytes.Buffer) []jsonResult {
+	var results []jsonResult
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r jsonResult
+		require.NoError(t, dec.Decode(&r), "Failed to decode JSON result")
+		results = append(results, r)
+	}
+	return results
+}
+
+func TestErrExitCode(t *testing.T) {
+	tests := []struct {
+		err       error
+		wantExit  int
+		wantClass string
+	}{
+		{tchannel.ErrTimeout, _exitTimeout, "timeout"},
+		{tchannel.ErrServerBusy, _exitBusy, "busy"},
+		{tchannel.NewSystemError(tchannel.ErrCodeDeclined, "declined"), _exitDeclined, "declined"},
+		{tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "no handler"), _exitBadRequest, "bad-request"},
+		{&health.CallError{Class: health.ClassNetwork, Err: errors.New("reset")}, _exitNetworkError, "network"},
+		{&health.CallError{Class: health.ClassDNS, Err: errors.New("no such host")}, _exitDNSFailure, "dns"},
+		{errors.New("unknown"), _exitUnknownUnhealthy, "error"},
+	}
+
+	for _, tt := range tests {
+		code := errExitCode(tt.err)
+		assert.Equal(t, tt.wantExit, code, "Unexpected exit code for %v", tt.err)
+		assert.Equal(t, tt.wantClass, errorClass(code), "Unexpected error class for %v", tt.err)
+	}
+
+	// Every error class should have its own exit code.
+	for _, class := range health.ErrorClasses {
+		assert.Equal(t, string(class), errorClass(_classExitCodes[class]), "Missing exit code for %v", class)
+	}
+}
+
+func TestErrExitCodeConnect(t *testing.T) {
+	// Find a port that nothing is listening on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	ln.Close()
+
+	result, err := healthCheck(ln.Addr().String(), "", checkOptions{serviceName: "svc", timeout: time.Second})
+	assert.Equal(t, _exitConnectionRefused, getExitCode(err), "Unexpected exit code for %v", result.err)
+}
+
+func TestPrintResultJSON(t *testing.T) {
+	healthy := setupServer(t, healthOk)
+	defer healthy.Close()
+
+	unhealthy := setupServer(t, healthNotOk)
+	defer unhealthy.Close()
+
+	noHandler := setupServer(t, nil)
+	defer noHandler.Close()
+
+	slow := setupServer(t, func(_ thrift.Context) (bool, string) {
+		time.Sleep(10 * time.Millisecond)
+		return true, ""
+	})
+	defer slow.Close()
+
+	tests := []struct {
+		msg  string
+		peer string
+		svc  string
+		want jsonResult
+	}{
+		{
+			msg:  "healthy",
+			peer: healthy.PeerInfo().HostPort,
+			svc:  "svc",
+			want: jsonResult{OK: true, Message: "hello world"},
+		},
+		{
+			msg:  "explicitly unhealthy",
+			peer: unhealthy.PeerInfo().HostPort,
+			svc:  "svc",
+			want: jsonResult{Message: "hello world", ErrorClass: "unhealthy", ExitCode: _exitExplicitUnhealthy},
+		},
+		{
+			msg:  "no health handler",
+			peer: noHandler.PeerInfo().HostPort,
+			svc:  "svc",
+			want: jsonResult{ErrorClass: "bad-request", ExitCode: _exitBadRequest},
+		},
+		{
+			msg:  "missing service",
+			peer: healthy.PeerInfo().HostPort,
+			want: jsonResult{
+				Error:      "Must specify a service name for the destination",
+				ErrorClass: "usage",
+				ExitCode:   _exitUsage,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var buf bytes.Buffer
+			result, err := healthCheck(tt.peer, "", checkOptions{serviceName: tt.svc, timeout: time.Second})
+			newPrinter(&buf, outputJSON).printResult(result, err)
+
+			results := decodeJSONResults(t, &buf)
+			require.Len(t, results, 1, "Expected a single JSON result")
+			got := results[0]
+
+			assert.Equal(t, tt.peer, got.Peer, "Unexpected peer")
+			assert.Equal(t, tt.svc, got.Service, "Unexpected service")
+			assert.Equal(t, tt.want.OK, got.OK, "Unexpected ok")
+			assert.Equal(t, tt.want.Message, got.Message, "Unexpected message")
+			assert.Equal(t, tt.want.ErrorClass, got.ErrorClass, "Unexpected error class")
+			assert.Equal(t, tt.want.ExitCode, got.ExitCode, "Unexpected exit code")
+			if tt.want.Error != "" {
+				assert.Equal(t, tt.want.Error, got.Error, "Unexpected error")
+			}
+			if tt.want.ExitCode != _exitUsage {
+				assert.Equal(t, tt.peer, got.ResolvedPeer, "Unexpected resolved peer")
+			}
+		})
+	}
+
+	t.Run("latency", func(t *testing.T) {
+		var buf bytes.Buffer
+		result, err := healthCheck(slow.PeerInfo().HostPort, "", checkOptions{serviceName: "svc", timeout: time.Second})
+		newPrinter(&buf, outputJSON).printResult(result, err)
+
+		results := decodeJSONResults(t, &buf)
+		require.Len(t, results, 1, "Expected a single JSON result")
+		assert.True(t, results[0].LatencyMs >= 10, "Latency %vms should include handler time", results[0].LatencyMs)
+	})
+}
+
+func TestPrintResultsJSON(t *testing.T) {
+	healthy := setupServer(t, healthOk)
+	defer healthy.Close()
+
+	unhealthy := setupServer(t, healthNotOk)
+	defer unhealthy.Close()
+
+	peers := []string{healthy.PeerInfo().HostPort, unhealthy.PeerInfo().HostPort}
+	results, err := healthCheckPeers(peers, checkOptions{serviceName: "svc", timeout: time.Second}, 2, requirement{kind: requireAll})
+	require.Error(t, err, "Expected all requirement to fail")
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputJSON).printResults(results, requirement{kind: requireAll}, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "Expected a JSON result per peer and a summary")
+	got := decodeJSONResults(t, bytes.NewBufferString(strings.Join(lines[:2], "\n")))
+	assert.True(t, got[0].OK, "Healthy peer should be OK")
+	assert.Equal(t, peers[0], got[0].Peer, "Unexpected peer")
+	assert.False(t, got[1].OK, "Unhealthy peer should not be OK")
+	assert.Equal(t, _exitExplicitUnhealthy, got[1].ExitCode, "Unexpected exit code")
+
+	var summary jsonSummary
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &summary), "Failed to decode summary")
+	assert.Equal(t, jsonSummary{
+		Summary:    true,
+		Service:    "svc",
+		Healthy:    1,
+		Total:      2,
+		Required:   2,
+		Require:    "all",
+		ErrorClass: "unhealthy",
+		ExitCode:   _exitExplicitUnhealthy,
+	}, summary, "Unexpected summary")
+
+	buf.Reset()
+	req := requirement{kind: requireAny}
+	results, err = healthCheckPeers(peers, checkOptions{serviceName: "svc", timeout: time.Second}, 2, req)
+	require.NoError(t, err, "Expected any requirement to pass")
+	newPrinter(&buf, outputJSON).printResults(results, req, err)
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "Expected a JSON result per peer and a summary")
+	summary = jsonSummary{}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &summary), "Failed to decode summary")
+	assert.Equal(t, jsonSummary{Summary: true, Service: "svc", OK: true, Healthy: 1, Total: 2, Required: 1, Require: "any"}, summary)
+
+	buf.Reset()
+	_, err = healthCheckPeers(nil, checkOptions{serviceName: "svc", timeout: time.Second}, 2, requirement{kind: requireAll})
+	newPrinter(&buf, outputJSON).printResults(nil, requirement{kind: requireAll}, err)
+	got = decodeJSONResults(t, &buf)
+	require.Len(t, got, 1, "Expected a JSON result for the usage error")
+	assert.Equal(t, "usage", got[0].ErrorClass, "Unexpected error class")
+}
+
+func TestPrintResultText(t *testing.T) {
+	var buf bytes.Buffer
+	p := newPrinter(&buf, outputText)
+
+	p.printResult(checkResult{Result: health.Result{Relay: "1.1.1.1:1"}}, nil)
+	assert.Equal(t, "Relay: 1.1.1.1:1\nOK\n", buf.String())
+
+	buf.Reset()
+	p.printResult(checkResult{}, exitError{_exitUsage, "usage error"})
+	assert.Equal(t, "usage error\n", buf.String())
+}
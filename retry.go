@@ -0,0 +1,60 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/uber/tcheck/health"
+)
+
+// retryOn is a flag.Value for the classes of errors to retry.
+type retryOn []health.ErrorClass
+
+func (r *retryOn) String() string {
+	classes := make([]string, len(*r))
+	for i, class := range *r {
+		classes[i] = string(class)
+	}
+	return strings.Join(classes, ",")
+}
+
+func (r *retryOn) Set(v string) error {
+	set := make(map[health.ErrorClass]bool)
+	for _, s := range strings.Split(v, ",") {
+		class, err := health.ParseErrorClass(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		set[class] = true
+	}
+
+	// Keep the classes in a stable order, regardless of the order they
+	// were specified in.
+	var classes retryOn
+	for _, class := range health.ErrorClasses {
+		if set[class] {
+			classes = append(classes, class)
+		}
+	}
+	*r = classes
+	return nil
+}
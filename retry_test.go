@@ -0,0 +1,141 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+func TestRetryOn(t *testing.T) {
+	var r retryOn
+	require.NoError(t, r.Set("timeout, busy,connection-refused"), "Set failed")
+	assert.Equal(t, "connection-refused,timeout,busy", r.String(), "Classes should be in a stable order")
+	assert.Equal(t, retryOn{health.ClassConnectionRefused, health.ClassTimeout, health.ClassBusy}, r)
+
+	assert.Error(t, r.Set("busy,unhealthy"), "Explicitly unhealthy results can't be retried")
+	assert.Equal(t, "timeout,busy,declined,network", retryClasses.String(), "Unexpected default")
+}
+
+// busyHandler returns a busy error for the first busyCalls health checks.
+type busyHandler struct {
+	meta.TChanMeta
+
+	calls     *int32
+	busyCalls int32
+}
+
+func (h busyHandler) Health(ctx thrift.Context, hr *meta.HealthRequest) (*meta.HealthStatus, error) {
+	if atomic.AddInt32(h.calls, 1) <= h.busyCalls {
+		return nil, tchannel.ErrServerBusy
+	}
+	return &meta.HealthStatus{Ok: true}, nil
+}
+
+func setupBusyServer(t *testing.T, busyCalls int32) *tchannel.Channel {
+	ch := setupServer(t, nil)
+	thrift.NewServer(ch).Register(meta.NewTChanMetaServer(busyHandler{calls: new(int32), busyCalls: busyCalls}))
+	return ch
+}
+
+func TestHealthCheckRetries(t *testing.T) {
+	server := setupBusyServer(t, 2)
+	defer server.Close()
+
+	opts := checkOptions{
+		serviceName: "svc",
+		timeout:     time.Second,
+		retry:       health.RetryPolicy{Retries: 2, Backoff: time.Millisecond, On: []health.ErrorClass{health.ClassBusy}},
+	}
+	result, err := healthCheck(server.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Expected health check to succeed after retries")
+	require.Len(t, result.Attempts, 3, "Unexpected number of attempts")
+	assert.Equal(t, tchannel.ErrServerBusy, result.Attempts[0].Err, "Unexpected first attempt")
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputText).printResult(result, err)
+	assert.Contains(t, buf.String(), "Attempt 2 failed after", "Missing failed attempt")
+	assert.Equal(t, "OK "+server.PeerInfo().HostPort+" (after 3 attempts)", result.String())
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printResult(result, err)
+	results := decodeJSONResults(t, &buf)
+	require.Len(t, results, 1, "Expected a single JSON result")
+	require.Len(t, results[0].Attempts, 3, "Unexpected attempts")
+	assert.Equal(t, "busy", results[0].Attempts[0].ErrorClass, "Unexpected error class")
+	assert.Empty(t, results[0].Attempts[2].Error, "Last attempt should succeed")
+}
+
+func TestIntegrationRetries(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	tests := []struct {
+		args     []string
+		wantExit int
+	}{
+		{
+			args:     []string{"--retries", "1"},
+			wantExit: _exitBusy,
+		},
+		{
+			args: []string{"--retries", "2"},
+		},
+		{
+			args:     []string{"--retries", "2", "--retryOn", "timeout"},
+			wantExit: _exitBusy,
+		},
+	}
+
+	for _, tt := range tests {
+		server := setupBusyServer(t, 2)
+
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			setArgs(append(tt.args, "--peer", server.PeerInfo().HostPort, "--serviceName", "svc", "--retryBackoff", "1ms")...)
+			main()
+		}()
+
+		<-done
+		server.Close()
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for %v", tt.args)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+// Behaviours for a step in a scenario served by the serve command.
+const (
+	behaviourHealthy   = "healthy"
+	behaviourUnhealthy = "unhealthy"
+	behaviourError     = "error"
+	behaviourNoHandler = "no-handler"
+)
+
+// duration is a time.Duration that's encoded in JSON as a string such as "5s".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// scenarioStep is the behaviour of the mock Meta server for a period of time.
+type scenarioStep struct {
+	// Duration is how long the step lasts. The last step lasts forever
+	// unless the scenario loops, so it may be omitted.
+	Duration duration `json:"duration"`
+
+	// Health is the behaviour for Meta calls, which defaults to healthy.
+	Health string `json:"health"`
+
+	// Message is the message for unhealthy results and errors.
+	Message string `json:"message"`
+
+	// Delay is added before responding to each call.
+	Delay duration `json:"delay"`
+}
+
+// scenario is a sequence of steps played back by the serve command.
+type scenario struct {
+	Steps []scenarioStep `json:"steps"`
+
+	// Loop restarts the scenario from the first step after the last step.
+	Loop bool `json:"loop"`
+}
+
+// loadScenario reads a scenario from a JSON file. If filename is empty, the
+// scenario is always healthy.
+func loadScenario(filename string) (*scenario, error) {
+	s := &scenario{}
+	if filename != "" {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(contents, s); err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %v", filename, err)
+		}
+	}
+	if len(s.Steps) == 0 {
+		s.Steps = []scenarioStep{{Health: behaviourHealthy}}
+	}
+	return s, s.validate()
+}
+
+func (s *scenario) validate() error {
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		switch step.Health {
+		case "":
+			step.Health = behaviourHealthy
+		case behaviourHealthy, behaviourUnhealthy, behaviourError, behaviourNoHandler:
+		default:
+			return fmt.Errorf("step %v: unknown health %q, must be healthy, unhealthy, error or no-handler", i+1, step.Health)
+		}
+
+		if step.Duration < 0 || step.Delay < 0 {
+			return fmt.Errorf("step %v: duration and delay must not be negative", i+1)
+		}
+		last := i == len(s.Steps)-1
+		if step.Duration == 0 && (s.Loop || !last) {
+			return fmt.Errorf("step %v: must specify a duration for every step except the last, or every step if looping", i+1)
+		}
+	}
+	return nil
+}
+
+// step returns the step that's active after elapsed time.
+func (s *scenario) step(elapsed time.Duration) scenarioStep {
+	if s.Loop {
+		var total time.Duration
+		for _, step := range s.Steps {
+			total += time.Duration(step.Duration)
+		}
+		elapsed %= total
+	}
+
+	for _, step := range s.Steps {
+		if elapsed < time.Duration(step.Duration) {
+			return step
+		}
+		elapsed -= time.Duration(step.Duration)
+	}
+	return s.Steps[len(s.Steps)-1]
+}
+
+// metaServer is a Meta server that responds to calls based on the step of
+// the scenario that's active when the call is received.
+type metaServer struct {
+	serviceName string
+	scenario    *scenario
+	idl         string
+	started     time.Time
+	now         func() time.Time
+}
+
+func newMetaServer(serviceName string, s *scenario, idl string) *metaServer {
+	return &metaServer{serviceName: serviceName, scenario: s, idl: idl, started: time.Now(), now: time.Now}
+}
+
+// respond waits for the step's delay, and returns an error if the step
+// doesn't respond successfully to method.
+func (s *metaServer) respond(method string) (scenarioStep, error) {
+	step := s.scenario.step(s.now().Sub(s.started))
+
+	// A slow peer doesn't stop when the caller times out.
+	time.Sleep(time.Duration(step.Delay))
+
+	switch step.Health {
+	case behaviourError:
+		return step, tchannel.NewSystemError(tchannel.ErrCodeUnexpected, step.Message)
+	case behaviourNoHandler:
+		// Match the error returned by TChannel for an unregistered method.
+		return step, tchannel.NewSystemError(tchannel.ErrCodeBadRequest,
+			"no handler for service %q and method %q", s.serviceName, "Meta::"+method)
+	}
+	return step, nil
+}
+
+func (s *metaServer) Health(ctx thrift.Context, _ *meta.HealthRequest) (*meta.HealthStatus, error) {
+	step, err := s.respond("health")
+	if err != nil {
+		return nil, err
+	}
+
+	status := &meta.HealthStatus{Ok: step.Health == behaviourHealthy}
+	if step.Message != "" {
+		status.Message = &step.Message
+	}
+	return status, nil
+}
+
+func (s *metaServer) ThriftIDL(ctx thrift.Context) (string, error) {
+	if _, err := s.respond("thriftIDL"); err != nil {
+		return "", err
+	}
+	if s.idl == "" {
+		return "", tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "no IDL, serve with --idlFile")
+	}
+	return s.idl, nil
+}
+
+func (s *metaServer) VersionInfo(ctx thrift.Context) (*meta.VersionInfo, error) {
+	if _, err := s.respond("versionInfo"); err != nil {
+		return nil, err
+	}
+	return localVersionInfo(), nil
+}
+
+// localVersionInfo returns the version info for servers run by tcheck.
+func localVersionInfo() *meta.VersionInfo {
+	return &meta.VersionInfo{
+		Language:        "go",
+		LanguageVersion: runtime.Version(),
+		Version:         tchannel.VersionInfo,
+	}
+}
+
+func runServe(p printer, opts checkOptions) {
+	ch, err := serve(*listen, *scenarioFile, *idlFile, opts)
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+		exit(err)
+		return
+	}
+	fmt.Fprintf(p.w, "Serving %v on %v\n", opts.serviceName, ch.PeerInfo().HostPort)
+
+	waitForSignal()
+	ch.Close()
+}
+
+// waitForSignal blocks until the process receives SIGINT or SIGTERM.
+func waitForSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	<-signals
+}
+
+// serve starts a mock Meta server for the service on hostPort, which plays
+// back the scenario in scenarioFile and serves the IDL in idlFile.
+func serve(hostPort, scenarioFile, idlFile string, opts checkOptions) (*tchannel.Channel, error) {
+	if opts.serviceName == "" {
+		return nil, exitError{_exitUsage, "Must specify a service name to serve"}
+	}
+	if hostPort == "" {
+		return nil, exitError{_exitUsage, "Must specify a host:port to listen on"}
+	}
+
+	s, err := loadScenario(scenarioFile)
+	if err != nil {
+		return nil, exitError{_exitUsage, fmt.Sprintf("Failed to load scenario: %v", err)}
+	}
+
+	var idl string
+	if idlFile != "" {
+		contents, err := ioutil.ReadFile(idlFile)
+		if err != nil {
+			return nil, exitError{_exitUsage, fmt.Sprintf("Failed to read IDL: %v", err)}
+		}
+		idl = string(contents)
+	}
+
+	ch, err := tchannel.NewChannel(opts.serviceName, nil)
+	if err != nil {
+		return nil, err
+	}
+	thrift.NewServer(ch).Register(meta.NewTChanMetaServer(newMetaServer(opts.serviceName, s, idl)))
+	if err := ch.ListenAndServe(hostPort); err != nil {
+		ch.Close()
+		return nil, exitError{_exitUnknown, fmt.Sprintf("Failed to listen on %v: %v", hostPort, err)}
+	}
+	return ch, nil
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func writeScenario(t *testing.T, contents string) (string, func()) {
+	return writeIDLFile(t, "scenario.json", contents)
+}
+
+func TestLoadScenario(t *testing.T) {
+	tests := []struct {
+		msg      string
+		contents string
+		wantErr  string
+	}{
+		{
+			msg:      "steps",
+			contents: `{"steps": [{"duration": "1s", "health": "unhealthy", "message": "starting"}, {"delay": "10ms"}]}`,
+		},
+		{
+			msg:      "no steps",
+			contents: `{}`,
+		},
+		{
+			msg:      "invalid JSON",
+			contents: `{"steps": [`,
+			wantErr:  "failed to parse",
+		},
+		{
+			msg:      "invalid duration",
+			contents: `{"steps": [{"duration": "soon"}]}`,
+			wantErr:  "invalid duration",
+		},
+		{
+			msg:      "unknown health",
+			contents: `{"steps": [{"health": "sick"}]}`,
+			wantErr:  `unknown health "sick"`,
+		},
+		{
+			msg:      "missing duration",
+			contents: `{"steps": [{"health": "unhealthy"}, {"health": "healthy"}]}`,
+			wantErr:  "must specify a duration",
+		},
+		{
+			msg:      "missing duration when looping",
+			contents: `{"loop": true, "steps": [{"duration": "1s"}, {"health": "error"}]}`,
+			wantErr:  "must specify a duration",
+		},
+	}
+
+	for _, tt := range tests {
+		filename, cleanup := writeScenario(t, tt.contents)
+		s, err := loadScenario(filename)
+		cleanup()
+
+		if tt.wantErr != "" {
+			require.Error(t, err, "%v: expected error", tt.msg)
+			assert.Contains(t, err.Error(), tt.wantErr, "%v: unexpected error", tt.msg)
+			continue
+		}
+		require.NoError(t, err, "%v: loadScenario failed", tt.msg)
+		for _, step := range s.Steps {
+			assert.NotEmpty(t, step.Health, "%v: health should default to healthy", tt.msg)
+		}
+	}
+
+	_, err := loadScenario("/tcheck/does/not/exist.json")
+	assert.Error(t, err, "Expected missing scenario file to fail")
+}
+
+func TestScenarioStep(t *testing.T) {
+	steps := []scenarioStep{
+		{Duration: duration(time.Second), Health: behaviourUnhealthy},
+		{Duration: duration(2 * time.Second), Health: behaviourHealthy},
+		{Duration: duration(time.Second), Health: behaviourError},
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		loop    bool
+		want    string
+	}{
+		{0, false, behaviourUnhealthy},
+		{time.Second, false, behaviourHealthy},
+		{2500 * time.Millisecond, false, behaviourHealthy},
+		{3 * time.Second, false, behaviourError},
+		{time.Minute, false, behaviourError},
+		{4 * time.Second, true, behaviourUnhealthy},
+		{5 * time.Second, true, behaviourHealthy},
+	}
+
+	for _, tt := range tests {
+		s := &scenario{Steps: steps, Loop: tt.loop}
+		assert.Equal(t, tt.want, s.step(tt.elapsed).Health, "Unexpected step after %v (loop: %v)", tt.elapsed, tt.loop)
+	}
+}
+
+func TestServe(t *testing.T) {
+	scenarioFile, cleanup := writeScenario(t, `{"steps": [
+		{"duration": "1h", "health": "unhealthy", "message": "starting"},
+		{"health": "healthy"}
+	]}`)
+	defer cleanup()
+
+	idlFile, cleanupIDL := writeIDLFile(t, "kv.thrift", testIDL)
+	defer cleanupIDL()
+
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+	ch, err := serve("127.0.0.1:0", scenarioFile, idlFile, opts)
+	require.NoError(t, err, "serve failed")
+	defer ch.Close()
+
+	peer := ch.PeerInfo().HostPort
+	_, err = healthCheck(peer, "", opts)
+	assert.Equal(t, exitError{_exitExplicitUnhealthy, "NOT OK starting\n"}, err, "Unexpected result")
+
+	result, err := fetchIDL(peer, "", opts)
+	require.NoError(t, err, "Failed to fetch IDL")
+	assert.Equal(t, testIDL, result.idls.Idls["svc.thrift"], "Unexpected IDL")
+
+	versions, err := versionInfo([]string{peer}, "", opts, 1)
+	require.NoError(t, err, "Failed to get version info")
+	assert.Equal(t, tchannel.VersionInfo, versions[0].info.Version, "Unexpected version")
+}
+
+func TestServeBadArgs(t *testing.T) {
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+
+	_, err := serve("127.0.0.1:0", "", "", checkOptions{})
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing service name to fail")
+
+	_, err = serve("", "", "", opts)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing host:port to fail")
+
+	_, err = serve("127.0.0.1:0", "/tcheck/does/not/exist.json", "", opts)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing scenario to fail")
+
+	_, err = serve("127.0.0.1:0", "", "/tcheck/does/not/exist.thrift", opts)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing IDL to fail")
+
+	_, err = serve("not-a-host-port", "", "", opts)
+	assert.Equal(t, _exitUnknown, getExitCode(err), "Expected invalid host:port to fail")
+}
+
+func TestMetaServerBehaviours(t *testing.T) {
+	tests := []struct {
+		step     scenarioStep
+		wantExit int
+		wantIDL  bool
+	}{
+		{
+			step:    scenarioStep{Health: behaviourHealthy},
+			wantIDL: true,
+		},
+		{
+			step:     scenarioStep{Health: behaviourUnhealthy, Message: "draining"},
+			wantExit: _exitExplicitUnhealthy,
+			wantIDL:  true,
+		},
+		{
+			step:     scenarioStep{Health: behaviourError, Message: "boom"},
+			wantExit: _exitUnknownUnhealthy,
+		},
+		{
+			step:     scenarioStep{Health: behaviourNoHandler},
+			wantExit: _exitBadRequest,
+		},
+		{
+			step:     scenarioStep{Health: behaviourHealthy, Delay: duration(time.Second)},
+			wantExit: _exitTimeout,
+		},
+	}
+
+	opts := checkOptions{serviceName: "svc", timeout: 50 * time.Millisecond}
+	for _, tt := range tests {
+		s := &scenario{Steps: []scenarioStep{tt.step}}
+		server := setupIDLServer(t, meta.NewTChanMetaServer(newMetaServer("svc", s, testIDL)))
+		peer := server.PeerInfo().HostPort
+
+		_, err := healthCheck(peer, "", opts)
+		if tt.wantExit == 0 {
+			assert.NoError(t, err, "%+v: expected healthy", tt.step)
+		} else {
+			assert.Equal(t, tt.wantExit, getExitCode(err), "%+v: unexpected exit code for %v", tt.step, err)
+		}
+
+		_, err = fetchIDL(peer, "", opts)
+		assert.Equal(t, tt.wantIDL, err == nil, "%+v: unexpected IDL error %v", tt.step, err)
+		server.Close()
+	}
+}
+
+func TestMetaServerNoIDL(t *testing.T) {
+	s := &scenario{Steps: []scenarioStep{{Health: behaviourHealthy}}}
+	server := setupIDLServer(t, meta.NewTChanMetaServer(newMetaServer("svc", s, "")))
+	defer server.Close()
+
+	_, err := fetchIDL(server.PeerInfo().HostPort, "", checkOptions{serviceName: "svc", timeout: time.Second})
+	require.Error(t, err, "Expected fetch to fail without an IDL")
+	assert.Contains(t, err.Error(), "--idlFile", "Unexpected error")
+}
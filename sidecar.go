@@ -0,0 +1,245 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
+)
+
+// localCheck is a check of a local process that's run for each Meta::health
+// call served by the sidecar command.
+type localCheck struct {
+	kind   string
+	target string
+	run    func(ctx context.Context, target string) error
+}
+
+func (c localCheck) String() string {
+	return c.kind + ":" + c.target
+}
+
+// _localCheckKinds are the functions that run each kind of local check.
+var _localCheckKinds = map[string]func(ctx context.Context, target string) error{
+	"http": checkHTTP,
+	"tcp":  checkTCP,
+	"exec": checkExec,
+	"file": checkFile,
+}
+
+// checkHTTP passes if a GET of the URL returns a 2xx or 3xx status.
+func checkHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %v", resp.Status)
+	}
+	return nil
+}
+
+// checkTCP passes if a connection to the host:port succeeds.
+func checkTCP(ctx context.Context, hostPort string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// _execKillGrace is how long checkExec waits for a command's output to be
+// closed after killing it.
+const _execKillGrace = time.Second
+
+// checkExec passes if the command exits with status 0. The command is run by
+// the shell, so it may include arguments.
+func checkExec(ctx context.Context, command string) error {
+	var out bytes.Buffer
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			if msg := strings.TrimSpace(out.String()); msg != "" {
+				return fmt.Errorf("%v: %v", err, msg)
+			}
+		}
+		return err
+	case <-ctx.Done():
+		// Processes started by the command keep the output open, so Wait
+		// only returns once they're all killed. Processes that can't be
+		// killed with the command may still keep it open, so don't wait
+		// forever.
+		killProcessGroup(cmd)
+		select {
+		case <-done:
+		case <-time.After(_execKillGrace):
+		}
+		return ctx.Err()
+	}
+}
+
+// checkFile passes if the file exists.
+func checkFile(_ context.Context, path string) error {
+	_, err := os.Stat(path)
+	return err
+}
+
+// localChecks is a flag.Value for the local checks run by the sidecar
+// command, each specified as kind:target.
+type localChecks []localCheck
+
+func (l *localChecks) String() string {
+	checks := make([]string, len(*l))
+	for i, c := range *l {
+		checks[i] = c.String()
+	}
+	return strings.Join(checks, ",")
+}
+
+func (l *localChecks) Set(v string) error {
+	parts := strings.SplitN(v, ":", 2)
+	run, ok := _localCheckKinds[parts[0]]
+	if len(parts) != 2 || !ok || parts[1] == "" {
+		return fmt.Errorf("invalid check %q, must be http:URL, tcp:host:port, exec:command or file:path", v)
+	}
+	*l = append(*l, localCheck{kind: parts[0], target: parts[1], run: run})
+	return nil
+}
+
+// sidecarServer is a Meta server whose health is the aggregate of local
+// checks, so processes without TChannel can be health checked using tcheck.
+type sidecarServer struct {
+	checks  []localCheck
+	timeout time.Duration
+}
+
+// Health runs all the local checks concurrently, and is healthy if they all
+// pass. The message summarizes any failed checks.
+func (s *sidecarServer) Health(ctx thrift.Context, _ *meta.HealthRequest) (*meta.HealthStatus, error) {
+	errs := make([]error, len(s.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range s.checks {
+		wg.Add(1)
+		go func(i int, c localCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, s.timeout)
+			defer cancel()
+			errs[i] = c.run(checkCtx, c.target)
+		}(i, c)
+	}
+	wg.Wait()
+
+	var failures []string
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", s.checks[i], err))
+		}
+	}
+
+	msg := fmt.Sprintf("%v of %v checks passed", len(s.checks)-len(failures), len(s.checks))
+	if len(failures) > 0 {
+		msg += "; " + strings.Join(failures, "; ")
+	}
+	return &meta.HealthStatus{Ok: len(failures) == 0, Message: &msg}, nil
+}
+
+// VersionInfo implements meta.TChanMeta.
+func (s *sidecarServer) VersionInfo(ctx thrift.Context) (*meta.VersionInfo, error) {
+	return localVersionInfo(), nil
+}
+
+// ThriftIDL implements meta.TChanMeta. The sidecar doesn't serve an IDL.
+func (s *sidecarServer) ThriftIDL(ctx thrift.Context) (string, error) {
+	return "", tchannel.NewSystemError(tchannel.ErrCodeBadRequest, "no IDL, the sidecar only serves Meta::health")
+}
+
+func runSidecar(p printer, opts checkOptions) {
+	ch, err := sidecar(*listen, checks, opts)
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+		exit(err)
+		return
+	}
+	fmt.Fprintf(p.w, "Serving %v on %v with %v checks\n", opts.serviceName, ch.PeerInfo().HostPort, len(checks))
+
+	waitForSignal()
+	ch.Close()
+}
+
+// sidecar starts a Meta server for the service on hostPort, whose health
+// is the aggregate of the local checks, each limited to the timeout.
+func sidecar(hostPort string, checks []localCheck, opts checkOptions) (*tchannel.Channel, error) {
+	if opts.serviceName == "" {
+		return nil, exitError{_exitUsage, "Must specify a service name to serve"}
+	}
+	if hostPort == "" {
+		return nil, exitError{_exitUsage, "Must specify a host:port to listen on"}
+	}
+	if len(checks) == 0 {
+		return nil, exitError{_exitUsage, "Must specify at least one local check"}
+	}
+	if opts.timeout <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive timeout"}
+	}
+
+	ch, err := tchannel.NewChannel(opts.serviceName, nil)
+	if err != nil {
+		return nil, err
+	}
+	thrift.NewServer(ch).Register(meta.NewTChanMetaServer(&sidecarServer{checks: checks, timeout: opts.timeout}))
+	if err := ch.ListenAndServe(hostPort); err != nil {
+		ch.Close()
+		return nil, exitError{_exitUnknown, fmt.Sprintf("Failed to listen on %v: %v", hostPort, err)}
+	}
+	return ch, nil
+}
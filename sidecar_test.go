@@ -0,0 +1,161 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestLocalChecksFlag(t *testing.T) {
+	var l localChecks
+	require.NoError(t, l.Set("http:http://127.0.0.1:8080/health?a=1,b=2"), "Set failed")
+	require.NoError(t, l.Set("tcp:127.0.0.1:5432"), "Set failed")
+	require.NoError(t, l.Set("exec:test -f /tmp/ready"), "Set failed")
+	assert.Equal(t, "http:http://127.0.0.1:8080/health?a=1,b=2,tcp:127.0.0.1:5432,exec:test -f /tmp/ready", l.String())
+	assert.Equal(t, "127.0.0.1:5432", l[1].target, "Unexpected target")
+
+	for _, v := range []string{"", "http", "http:", "ping:127.0.0.1"} {
+		assert.Error(t, l.Set(v), "Expected %q to fail", v)
+	}
+}
+
+func TestLocalCheckKinds(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	defer ln.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	closed.Close()
+
+	f, err := ioutil.TempFile("", "tcheck-ready")
+	require.NoError(t, err, "Failed to create file")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	tests := []struct {
+		check   string
+		wantErr bool
+	}{
+		{check: "http:" + ok.URL},
+		{check: "http:" + failing.URL, wantErr: true},
+		{check: "tcp:" + ln.Addr().String()},
+		{check: "tcp:" + closed.Addr().String(), wantErr: true},
+		{check: "exec:true"},
+		{check: "exec:echo not ready; exit 1", wantErr: true},
+		{check: "exec:sleep 1", wantErr: true},
+		{check: "file:" + f.Name()},
+		{check: "file:/tcheck/does/not/exist", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var l localChecks
+		require.NoError(t, l.Set(tt.check), "Set failed")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		err := l[0].run(ctx, l[0].target)
+		cancel()
+		assert.Equal(t, tt.wantErr, err != nil, "%v: unexpected error %v", tt.check, err)
+	}
+}
+
+func TestCheckExecTimeoutKillsChildren(t *testing.T) {
+	// The background sleep keeps the output open after the shell is killed,
+	// so checkExec only returns promptly if it's killed too.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := checkExec(ctx, "sleep 30 & wait")
+	assert.Equal(t, context.DeadlineExceeded, err, "Expected check to time out")
+	assert.True(t, time.Since(start) < _execKillGrace, "Expected the command's children to be killed")
+}
+
+func TestSidecar(t *testing.T) {
+	f, err := ioutil.TempFile("", "tcheck-ready")
+	require.NoError(t, err, "Failed to create file")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	var l localChecks
+	require.NoError(t, l.Set("file:"+f.Name()), "Set failed")
+	require.NoError(t, l.Set("exec:true"), "Set failed")
+
+	opts := checkOptions{serviceName: "legacy", timeout: time.Second}
+	ch, err := sidecar("127.0.0.1:0", l, opts)
+	require.NoError(t, err, "sidecar failed")
+	defer ch.Close()
+
+	result, err := healthCheck(ch.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Expected sidecar to be healthy")
+	assert.Equal(t, "2 of 2 checks passed", result.Message, "Unexpected message")
+
+	os.Remove(f.Name())
+	result, err = healthCheck(ch.PeerInfo().HostPort, "", opts)
+	assert.Equal(t, _exitExplicitUnhealthy, getExitCode(err), "Expected sidecar to be unhealthy")
+	assert.Contains(t, result.Message, "1 of 2 checks passed; file:"+f.Name()+": ", "Unexpected message")
+
+	versions, err := versionInfo([]string{ch.PeerInfo().HostPort}, "", opts, 1)
+	require.NoError(t, err, "Failed to get version info")
+	assert.Equal(t, "go", versions[0].info.Language, "Unexpected language")
+}
+
+func TestSidecarBadArgs(t *testing.T) {
+	var l localChecks
+	require.NoError(t, l.Set("exec:true"), "Set failed")
+	opts := checkOptions{serviceName: "legacy", timeout: time.Second}
+
+	tests := []struct {
+		hostPort string
+		checks   localChecks
+		opts     checkOptions
+		wantExit int
+	}{
+		{"127.0.0.1:0", l, checkOptions{timeout: time.Second}, _exitUsage},
+		{"", l, opts, _exitUsage},
+		{"127.0.0.1:0", nil, opts, _exitUsage},
+		{"127.0.0.1:0", l, checkOptions{serviceName: "legacy"}, _exitUsage},
+		{"not-a-host-port", l, opts, _exitUnknown},
+	}
+
+	for _, tt := range tests {
+		_, err := sidecar(tt.hostPort, tt.checks, tt.opts)
+		assert.Equal(t, tt.wantExit, getExitCode(err), "Unexpected exit code for %+v", tt)
+	}
+}
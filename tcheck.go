@@ -23,26 +23,55 @@ package main
 import (
 	"flag"
 	"fmt"
-	"net"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/uber/tcheck/internal/gen-go/meta"
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
 
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
 )
 
-//go:generate thrift-gen --generateThrift --inputFile meta.thrift --outputDir ./internal/gen-go
+//go:generate thrift-gen --generateThrift --inputFile meta.thrift --outputDir ./gen-go
 
 const (
-	_serviceName = "tcheck"
+	_serviceName      = "tcheck"
+	_defaultHostsFile = "/etc/uber/hyperbahn/hosts.json"
 
 	_exitUnknown           = 1
 	_exitUsage             = 2
 	_exitUnknownUnhealthy  = 3
 	_exitExplicitUnhealthy = 4
+	_exitIDLBreaking       = 5
+
+	// Exit codes for health checks that failed with a TChannel error,
+	// so that a process that's down can be told apart from one that's
+	// missing a handler or overloaded.
+	_exitConnectionRefused = 6
+	_exitDNSFailure        = 7
+	_exitTimeout           = 8
+	_exitBusy              = 9
+	_exitDeclined          = 10
+	_exitBadRequest        = 11
+	_exitNetworkError      = 12
+	_exitProtocolError     = 13
+
+	_exitWaitTimeout = 14
+
+	// _exitSlow is the exit code for a peer that's healthy, but slower
+	// than --maxLatency, and _exitWrongProcess is for a peer that's not
+	// the process in --expectProcess.
+	_exitSlow         = 15
+	_exitWrongProcess = 16
+
+	// Exit codes for the call command when the method throws a declared
+	// exception, or the result doesn't match an --assert.
+	_exitCallException   = 17
+	_exitAssertionFailed = 18
 )
 
 var _osExit = os.Exit
@@ -57,20 +86,200 @@ func (e exitError) Error() string {
 }
 
 var (
-	peer        = flag.String("peer", "", "Peer host:port to health check")
-	serviceName = flag.String("serviceName", "", "Service name to health check")
-	timeout     = flag.Duration("timeout", time.Second, "Timeout for the health check")
+	peers        peerList
+	hostsFile    = flag.String("hostsFile", _defaultHostsFile, "Hyperbahn hosts file used to route the health check")
+	serviceName  = flag.String("serviceName", "", "Service name to health check")
+	timeout      = flag.Duration("timeout", time.Second, "Timeout for each health check attempt")
+	concurrency  = flag.Int("concurrency", 10, "Maximum number of peers to health check concurrently")
+	requirePeers = requirement{kind: requireAll}
+	output       = outputText
+	healthType   healthTypeFlag
+	outputDir    = flag.String("outputDir", "", "Directory to write the IDL to for the idl command, instead of printing it")
+	idlFile      = flag.String("idlFile", "", "Local Thrift IDL to compare the served IDL against for the idl-diff command, to serve with the serve command, or to encode calls with the call command")
+	watchMode    = flag.Bool("watch", false, "Health check repeatedly, printing only when the result changes, until interrupted")
+	interval     = flag.Duration("interval", 5*time.Second, "Interval between health checks with --watch, or the maximum backoff with --waitFor")
+	waitUntil    waitCondition
+	maxWait      = flag.Duration("maxWait", time.Minute, "Maximum time to wait with --waitFor")
+	consecutive  = flag.Int("consecutive", 1, "Number of consecutive health checks that must match with --waitFor")
+	retries      = flag.Int("retries", 0, "Number of times to retry a health check that fails with an error in --retryOn")
+	retryBackoff = flag.Duration("retryBackoff", 100*time.Millisecond, "Delay before the first retry, which doubles after each retry")
+	retryClasses = retryOn(health.DefaultRetryOn)
+	deadline     = flag.Duration("deadline", 0, "Overall timeout for a health check including retries, or 0 for no limit")
+	listen       = flag.String("listen", "127.0.0.1:0", "Host:port for the serve, exporter, gateway and sidecar commands to listen on")
+	scenarioFile = flag.String("scenario", "", "JSON scenario file for the serve command, which is always healthy without one")
+	cacheTTL     = flag.Duration("cacheTTL", time.Second, "Time to cache health check results for the gateway command, or 0 to only share concurrent checks")
+	routesFile   = flag.String("routes", "", "JSON file mapping names to a service and peer for the gateway command")
+	routesOnly   = flag.Bool("routesOnly", false, "Only health check the --routes with the gateway command, not any {service}/{hostport}")
+	checks       localChecks
+	warning      = flag.Duration("warning", 0, "Latency at which a healthy result is a warning with --format nagios, or 0 for none")
+	critical     = flag.Duration("critical", 0, "Latency at which a healthy result is critical with --format nagios, or 0 for none")
+	maxLatency   = flag.Duration("maxLatency", 0, "Maximum latency of the health check call for a healthy peer, or 0 for no limit")
+	showTiming   = flag.Bool("timing", false, "Print the time taken to resolve, connect, handshake and make the health check call")
+	mode         = modeHealth
+	wantProcess  = flag.String("expectProcess", "", "Process name the peer must report when connecting, ignoring the [pid] suffix")
+	method       = flag.String("method", "", "Method to invoke with the call command, e.g. Service::function")
+	callArgs     = flag.String("args", "{}", "JSON object of arguments for --method, keyed by argument name")
+	asserts      assertions
+	argScheme    = flag.String("as", "thrift", "Arg scheme of the health check call: thrift, json or raw")
+	endpoint     = flag.String("endpoint", "", "Method called by the health check, e.g. Service::method for thrift. Defaults to Meta::health for thrift, and health for json and raw")
+	caller       = flag.String("caller", _serviceName, "Service name to make calls from, which peers see as the caller")
+	shardKey     = flag.String("shardKey", "", "Shard key transport header to send with each call")
+	routingKey   = flag.String("routingKey", "", "Routing key transport header to send with each call")
+	delegate     = flag.String("routingDelegate", "", "Routing delegate transport header to send with each call")
+	appHeaders   headers
 )
 
+func init() {
+	flag.Var(&peers, "peer", "Peer host:port to health check, overrides --hostsFile. May be repeated or comma-separated")
+	flag.Var(&requirePeers, "require", "Peers that must be healthy when checking multiple peers: all, any, quorum or N%")
+	flag.Var(&output, "output", "Output format: text, json or nagios")
+	flag.Var(&output, "format", "Output format, the same as --output")
+	flag.Var(&healthType, "healthType", "Type of health check to request: process or traffic")
+	flag.Var(&retryClasses, "retryOn", "Comma-separated classes of errors to retry, e.g. timeout,busy,declined,network")
+	flag.Var(&waitUntil, "waitFor", "Health check until the peer is healthy, unhealthy or gone, or --maxWait passes")
+	flag.Var(&mode, "mode", "Check to make: health calls Meta::health, and ping only pings the peer")
+	flag.Var(&checks, "check", "Local check for the sidecar command: http:URL, tcp:host:port, exec:command or file:path. May be repeated")
+	flag.Var(&appHeaders, "header", "Application header to send with each call as key=value. May be repeated")
+	flag.Var(&asserts, "assert", "Field of the call command's result and the JSON value it must have, e.g. ok=true. May be repeated")
+}
+
+// checkOptions are the options used for each Meta call.
+type checkOptions struct {
+	serviceName string
+	timeout     time.Duration
+
+	// healthType is the type of health check to request. If it's nil, no
+	// HealthRequest is sent, which peers treat as a process health check.
+	healthType *meta.HealthRequestType
+
+	// retry is the retry policy for health checks. timeout applies to each
+	// attempt, while deadline applies to all attempts.
+	retry    health.RetryPolicy
+	deadline time.Duration
+
+	// maxLatency is the maximum latency of the Meta::health call, which is
+	// checked using the call's own timing, and measureTiming measures the
+	// time taken to resolve and connect to the peer.
+	maxLatency    time.Duration
+	measureTiming bool
+
+	// mode is whether to call Meta::health, or only ping the peer.
+	mode checkMode
+
+	// expectProcess is the process name the peer must report.
+	expectProcess string
+
+	// format is the arg scheme of the health check call, and endpoint is
+	// the method that's called.
+	format   tchannel.Format
+	endpoint string
+
+	// caller is the service name that calls are made from, which defaults
+	// to tcheck, and the remaining fields are sent with each call.
+	caller          string
+	shardKey        string
+	routingKey      string
+	routingDelegate string
+	headers         map[string]string
+}
+
 func main() {
-	flag.Parse()
+	cmd, args := parseCommand(os.Args[1:])
+	flag.CommandLine.Parse(args)
+
+	p := newPrinter(os.Stdout, output)
+	opts := checkOptions{
+		serviceName: *serviceName,
+		timeout:     *timeout,
+		healthType:  healthType.value,
+		retry: health.RetryPolicy{
+			Retries: *retries,
+			Backoff: *retryBackoff,
+			On:      []health.ErrorClass(retryClasses),
+		},
+		deadline:        *deadline,
+		maxLatency:      *maxLatency,
+		measureTiming:   *showTiming,
+		mode:            mode,
+		expectProcess:   *wantProcess,
+		format:          tchannel.Format(*argScheme),
+		endpoint:        *endpoint,
+		caller:          *caller,
+		shardKey:        *shardKey,
+		routingKey:      *routingKey,
+		routingDelegate: *delegate,
+		headers:         appHeaders,
+	}
 
-	if err := healthCheck(*peer, *serviceName, *timeout); err != nil {
+	switch cmd {
+	case "":
+		runHealthCheck(p, opts)
+	case "version-info":
+		runVersionInfo(p, opts)
+	case "idl":
+		runIDL(p, opts)
+	case "idl-diff":
+		runIDLDiff(p, opts)
+	case "serve":
+		runServe(p, opts)
+	case "exporter":
+		runExporter(p, opts)
+	case "gateway":
+		runGateway(p, opts)
+	case "sidecar":
+		runSidecar(p, opts)
+	case "call":
+		runCall(p, opts)
+	default:
+		err := exitError{_exitUsage, fmt.Sprintf("Unknown command %q, must be version-info, idl, idl-diff, serve, exporter, gateway, sidecar or call", cmd)}
 		fmt.Println(err)
-		_osExit(getExitCode(err))
+		exit(err)
 	}
+}
 
-	fmt.Println("OK")
+// parseCommand splits the command, if any, from the flags in args.
+func parseCommand(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", args
+	}
+	return args[0], args[1:]
+}
+
+func runHealthCheck(p printer, opts checkOptions) {
+	if p.format == outputNagios {
+		runNagios(p, opts)
+		return
+	}
+	if *watchMode {
+		runWatch(p, opts)
+		return
+	}
+	if waitUntil != waitNone {
+		runWait(p, opts)
+		return
+	}
+
+	if len(peers) > 1 {
+		results, err := healthCheckPeers(peers, opts, *concurrency, requirePeers)
+		p.printResults(results, requirePeers, err)
+		exit(err)
+		return
+	}
+
+	var peer string
+	if len(peers) == 1 {
+		peer = peers[0]
+	}
+
+	result, err := healthCheck(peer, *hostsFile, opts)
+	p.printResult(result, err)
+	exit(err)
+}
+
+func exit(err error) {
+	if err != nil {
+		_osExit(getExitCode(err))
+	}
 }
 
 func getExitCode(err error) int {
@@ -80,60 +289,252 @@ func getExitCode(err error) int {
 	return _exitUnknown
 }
 
-func healthCheck(peer, serviceName string, timeout time.Duration) error {
-	if peer == "" {
-		return exitError{_exitUsage, "Must specify a peer to health check"}
-	}
-	if serviceName == "" {
+func validateArgs(opts checkOptions) error {
+	if opts.serviceName == "" {
 		return exitError{_exitUsage, "Must specify a service name for the destination"}
 	}
-	if timeout <= 0 {
+	if opts.timeout <= 0 {
 		return exitError{_exitUsage, "Must specify a positive timeout"}
 	}
+	if opts.retry.Retries < 0 {
+		return exitError{_exitUsage, "Must specify a non-negative number of retries"}
+	}
+	if opts.retry.Backoff < 0 {
+		return exitError{_exitUsage, "Must specify a non-negative retry backoff"}
+	}
+	if opts.deadline < 0 {
+		return exitError{_exitUsage, "Must specify a non-negative deadline"}
+	}
+	if opts.maxLatency < 0 {
+		return exitError{_exitUsage, "Must specify a non-negative max latency"}
+	}
+	return nil
+}
 
-	ch, err := tchannel.NewChannel(_serviceName, nil)
+// healthCheck calls Meta::health on the service. If peer is specified, the
+// call is made directly to that peer, otherwise it's routed through the
+// Hyperbahn relays listed in hostsFile, and the result includes the relay used.
+func healthCheck(peer, hostsFile string, opts checkOptions) (checkResult, error) {
+	c, err := newChecker(peer, hostsFile, opts)
 	if err != nil {
-		return err
+		return checkResult{Result: health.Result{Peer: peer, ServiceName: opts.serviceName}}, err
 	}
+	defer c.close()
 
-	peer = remapLocalhost(peer)
-	ch.Peers().Add(peer)
-	thriftClient := thrift.NewClient(ch, serviceName, nil)
-	client := meta.NewTChanMetaClient(thriftClient)
+	result := c.check()
+	return result, result.exitErr()
+}
+
+// checker health checks a single peer, or the service through Hyperbahn,
+// using the same channel for every check.
+type checker struct {
+	hc   *health.Checker
+	ch   *tchannel.Channel
+	peer string
+	opts checkOptions
+}
 
-	ctx, cancel := thrift.NewContext(timeout)
-	defer cancel()
+func newChecker(peer, hostsFile string, opts checkOptions) (*checker, error) {
+	if peer == "" && hostsFile == "" {
+		return nil, exitError{_exitUsage, "Must specify a peer or a Hyperbahn hosts file to health check"}
+	}
+	if peer == "" && opts.mode == modePing {
+		return nil, exitError{_exitUsage, "Must specify a peer to ping"}
+	}
+	if peer == "" && opts.expectProcess != "" {
+		return nil, exitError{_exitUsage, "Must specify a peer to check the process of"}
+	}
 
-	val, err := client.Health(ctx)
+	var (
+		ch  *tchannel.Channel
+		err error
+	)
+	if peer != "" {
+		ch, err = newChannel(opts)
+	} else {
+		ch, err = newHyperbahnChannel(opts, hostsFile)
+	}
 	if err != nil {
-		return exitError{_exitUnknownUnhealthy, fmt.Sprintf("NOT OK %v\nError: %v\n", serviceName, err)}
+		return nil, err
 	}
-	if val.Ok != true {
-		return exitError{_exitExplicitUnhealthy, fmt.Sprintf("NOT OK %v\n", val.GetMessage())}
+
+	hc, err := newHealthChecker(ch, opts)
+	if err != nil {
+		ch.Close()
+		return nil, err
 	}
+	return &checker{hc: hc, ch: ch, peer: peer, opts: opts}, nil
+}
 
-	return nil
+func (c *checker) check() checkResult {
+	return checkTarget(c.hc, c.peer, c.opts)
+}
+
+func (c *checker) close() {
+	c.ch.Close()
 }
 
-// TChannel tools remap the string "localhost" to the best public IP on the host.
-// The protocol assumes services only listen on a single host:port, so the
-// client libraries tend to listen on a single interface. This localhost remapping
-// makes it easier for users to health check an instance on the current host
-// without having to find the public IP of the machine.
-func remapLocalhost(hostPort string) string {
-	host, port, err := net.SplitHostPort(hostPort)
+// newHealthChecker returns a health.Checker for the options that makes
+// health checks using ch.
+func newHealthChecker(ch *tchannel.Channel, opts checkOptions) (*health.Checker, error) {
+	if err := validateArgs(opts); err != nil {
+		return nil, err
+	}
+
+	hc, err := health.NewChecker(health.Options{
+		ServiceName:     opts.serviceName,
+		Timeout:         opts.timeout,
+		HealthType:      opts.healthType,
+		Retry:           opts.retry,
+		RemapLocalhost:  true,
+		Channel:         ch,
+		MaxLatency:      opts.maxLatency,
+		MeasureTiming:   opts.measureTiming,
+		ExpectProcess:   opts.expectProcess,
+		Format:          opts.format,
+		Endpoint:        opts.endpoint,
+		ShardKey:        opts.shardKey,
+		RoutingKey:      opts.routingKey,
+		RoutingDelegate: opts.routingDelegate,
+		Headers:         opts.headers,
+	})
 	if err != nil {
-		return hostPort
+		return nil, exitError{_exitUsage, fmt.Sprintf("Invalid options: %v", err)}
+	}
+	return hc, nil
+}
+
+// checkTarget health checks the target using hc, limiting all attempts to
+// the deadline if it's set.
+func checkTarget(hc *health.Checker, target string, opts checkOptions) checkResult {
+	ctx := context.Background()
+	if opts.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.deadline)
+		defer cancel()
+	}
+
+	if opts.mode == modePing {
+		result, err := hc.Ping(ctx, target)
+		return checkResult{Result: result, err: err, timed: true}
+	}
+
+	result, err := hc.Check(ctx, target)
+	if _, ok := err.(*health.UnhealthyError); ok {
+		// The peer responded, and the result reports that it's unhealthy.
+		err = nil
 	}
+	return checkResult{Result: result, err: err, timed: opts.measureTiming}
+}
+
+// callerName returns the service name that calls are made from.
+func (o checkOptions) callerName() string {
+	if o.caller == "" {
+		return _serviceName
+	}
+	return o.caller
+}
+
+// newContext returns a context for a single call, with the timeout and
+// headers in opts.
+func newContext(opts checkOptions) (thrift.Context, context.CancelFunc) {
+	return tchannel.NewContextBuilder(opts.timeout).
+		SetShardKey(opts.shardKey).
+		SetRoutingKey(opts.routingKey).
+		SetRoutingDelegate(opts.routingDelegate).
+		SetHeaders(opts.headers).
+		Build()
+}
+
+// newChannel returns a channel that makes calls directly to peers.
+func newChannel(opts checkOptions) (*tchannel.Channel, error) {
+	return tchannel.NewChannel(opts.callerName(), nil)
+}
+
+// newHyperbahnChannel returns a channel that routes calls through the
+// Hyperbahn relays listed in hostsFile.
+func newHyperbahnChannel(opts checkOptions, hostsFile string) (*tchannel.Channel, error) {
+	ch, err := health.NewHyperbahnChannel(opts.callerName(), hostsFile)
+	if err != nil {
+		return nil, exitError{_exitUsage, fmt.Sprintf("Failed to load Hyperbahn hosts file %v: %v", hostsFile, err)}
+	}
+	return ch, nil
+}
 
-	if !strings.EqualFold(host, "localhost") {
-		return hostPort
+// healthTypeFlag is a flag.Value for the type of health check to request.
+type healthTypeFlag struct {
+	value *meta.HealthRequestType
+}
+
+func (f *healthTypeFlag) String() string {
+	if f.value == nil {
+		return ""
 	}
+	return strings.ToLower(f.value.String())
+}
 
-	ip, err := tchannel.ListenIP()
+func (f *healthTypeFlag) Set(v string) error {
+	t, err := meta.HealthRequestTypeFromString(strings.ToUpper(v))
 	if err != nil {
-		return hostPort
+		return fmt.Errorf("unknown health type %q, must be process or traffic", v)
 	}
+	f.value = &t
+	return nil
+}
+
+// checkMode is the kind of check to make.
+type checkMode int
 
-	return net.JoinHostPort(ip.String(), port)
+const (
+	modeHealth checkMode = iota
+	modePing
+)
+
+func (m *checkMode) String() string {
+	if *m == modePing {
+		return "ping"
+	}
+	return "health"
+}
+
+func (m *checkMode) Set(v string) error {
+	switch v {
+	case "health":
+		*m = modeHealth
+	case "ping":
+		*m = modePing
+	default:
+		return fmt.Errorf("unknown mode %q, must be health or ping", v)
+	}
+	return nil
+}
+
+// headers is a flag.Value for application headers given as key=value, which
+// may be repeated.
+type headers map[string]string
+
+func (h *headers) String() string {
+	keys := make([]string, 0, len(*h))
+	for k := range *h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + (*h)[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (h *headers) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("header %q must be of the form key=value", v)
+	}
+	if *h == nil {
+		*h = make(headers)
+	}
+	(*h)[parts[0]] = parts[1]
+	return nil
 }
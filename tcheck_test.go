@@ -21,20 +21,27 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"flag"
+	"io/ioutil"
 	"net"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/uber/tcheck/internal/gen-go/meta"
+	"github.com/uber/tcheck/gen-go/meta"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
 	"github.com/uber/tchannel-go/testutils"
 	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
 )
 
 func healthNotOk(ctx thrift.Context) (ok bool, message string) {
@@ -67,6 +74,25 @@ func getPort(t *testing.T, hostPort string) string {
 	return port
 }
 
+// setArgs sets the command line arguments for main, and resets all flags to
+// their defaults since flag values persist across calls to flag.Parse.
+func setArgs(args ...string) {
+	flag.VisitAll(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "test.") {
+			f.Value.Set(f.DefValue)
+		}
+	})
+
+	// Flags with no default value can't be reset using Set.
+	peers = nil
+	healthType = healthTypeFlag{}
+	waitUntil = waitNone
+	checks = nil
+	asserts = nil
+	appHeaders = nil
+	os.Args = append([]string{"tcheck"}, args...)
+}
+
 func setupServer(t *testing.T, fn thrift.HealthFunc) *tchannel.Channel {
 	opts := testutils.NewOpts().
 		SetServiceName("svc").
@@ -101,13 +127,14 @@ func TestHealthCheckBadArgs(t *testing.T) {
 	publicHandler := setupListenIPServer(t)
 
 	tests := []struct {
-		msg      string
-		peer     string
-		svc      string
-		timeout  time.Duration
-		fn       thrift.HealthFunc
-		wantExit int
-		wantErr  string
+		msg       string
+		peer      string
+		hostsFile string
+		svc       string
+		timeout   time.Duration
+		fn        thrift.HealthFunc
+		wantExit  int
+		wantErr   string
 	}{
 		{
 			msg:      "missing service",
@@ -121,6 +148,13 @@ func TestHealthCheckBadArgs(t *testing.T) {
 			svc:      "svc",
 			wantExit: _exitUsage,
 		},
+		{
+			msg:       "missing hosts file",
+			hostsFile: "/tcheck/does/not/exist.json",
+			svc:       "svc",
+			wantExit:  _exitUsage,
+			wantErr:   "Hyperbahn hosts file",
+		},
 		{
 			msg:      "negative timeout",
 			peer:     "127.0.0.1",
@@ -144,7 +178,7 @@ func TestHealthCheckBadArgs(t *testing.T) {
 			msg:      "no health handler",
 			peer:     noHandler.PeerInfo().HostPort,
 			svc:      "svc",
-			wantExit: _exitUnknownUnhealthy,
+			wantExit: _exitBadRequest,
 			wantErr:  "ErrCodeBadRequest",
 		},
 		{
@@ -159,7 +193,7 @@ func TestHealthCheckBadArgs(t *testing.T) {
 			peer:     timeoutHandler.PeerInfo().HostPort,
 			svc:      "svc",
 			timeout:  50 * time.Millisecond,
-			wantExit: _exitUnknownUnhealthy,
+			wantExit: _exitTimeout,
 			wantErr:  "ErrCodeTimeout",
 		},
 	}
@@ -170,7 +204,7 @@ func TestHealthCheckBadArgs(t *testing.T) {
 			if tt.timeout != 0 {
 				timeout = tt.timeout
 			}
-			err := healthCheck(tt.peer, tt.svc, timeout)
+			_, err := healthCheck(tt.peer, tt.hostsFile, checkOptions{serviceName: tt.svc, timeout: timeout})
 			if tt.wantExit > 0 {
 				require.Error(t, err)
 				assert.Equal(t, tt.wantExit, getExitCode(err), "Unexpected error code")
@@ -183,6 +217,43 @@ func TestHealthCheckBadArgs(t *testing.T) {
 	}
 }
 
+func writeHostsFile(t *testing.T, hostPorts ...string) string {
+	f, err := ioutil.TempFile("", "hosts.json")
+	require.NoError(t, err, "Failed to create hosts file")
+	defer f.Close()
+
+	require.NoError(t, json.NewEncoder(f).Encode(hostPorts), "Failed to write hosts file")
+	return f.Name()
+}
+
+func TestHealthCheckHyperbahn(t *testing.T) {
+	// Any TChannel server can act as the relay for its own service.
+	relay := setupServer(t, healthOk)
+	defer relay.Close()
+
+	hostsFile := writeHostsFile(t, relay.PeerInfo().HostPort)
+	defer os.Remove(hostsFile)
+
+	result, err := healthCheck("", hostsFile, checkOptions{serviceName: "svc", timeout: time.Second})
+	require.NoError(t, err, "Health check through Hyperbahn failed")
+	assert.Equal(t, relay.PeerInfo().HostPort, result.Relay, "Unexpected relay")
+
+	// --peer overrides the hosts file and doesn't report a relay.
+	result, err = healthCheck(relay.PeerInfo().HostPort, "/tcheck/does/not/exist.json", checkOptions{serviceName: "svc", timeout: time.Second})
+	require.NoError(t, err, "Health check with peer failed")
+	assert.Empty(t, result.Relay, "Relay should not be reported for direct health checks")
+}
+
+func TestHealthCheckHyperbahnInvalidHostsFile(t *testing.T) {
+	hostsFile := writeHostsFile(t)
+	defer os.Remove(hostsFile)
+
+	_, err := healthCheck("", hostsFile, checkOptions{serviceName: "svc", timeout: time.Second})
+	require.Error(t, err, "Expected empty hosts file to fail")
+	assert.Equal(t, _exitUsage, getExitCode(err), "Unexpected exit code")
+	assert.Contains(t, err.Error(), "at least one initial node", "Unexpected error")
+}
+
 func TestIntegrationSuccess(t *testing.T) {
 	server := setupServer(t, nil)
 	defer server.Close()
@@ -190,7 +261,7 @@ func TestIntegrationSuccess(t *testing.T) {
 	// Set up a default health handler.
 	thrift.NewServer(server)
 
-	os.Args = []string{"tcheck", "--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName()}
+	setArgs("--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName())
 	main()
 }
 
@@ -199,7 +270,7 @@ type unhealthyHandler struct {
 	meta.TChanMeta
 }
 
-func (unhealthyHandler) Health(_ thrift.Context) (*meta.HealthStatus, error) {
+func (unhealthyHandler) Health(_ thrift.Context, _ *meta.HealthRequest) (*meta.HealthStatus, error) {
 	return &meta.HealthStatus{
 		Ok: false,
 	}, nil
@@ -212,7 +283,7 @@ func TestIntegrationNotOKNoMessage(t *testing.T) {
 	tServer := thrift.NewServer(server)
 	tServer.Register(meta.NewTChanMetaServer(unhealthyHandler{}))
 
-	err := healthCheck(server.PeerInfo().HostPort, server.ServiceName(), time.Second)
+	_, err := healthCheck(server.PeerInfo().HostPort, "", checkOptions{serviceName: server.ServiceName(), timeout: time.Second})
 	require.Error(t, err, "Expected health check to fail")
 	assert.Equal(t, _exitExplicitUnhealthy, getExitCode(err), "Unexpected exit code")
 }
@@ -235,43 +306,606 @@ func TestIntegrationError(t *testing.T) {
 	go func() {
 		defer close(done)
 
-		os.Args = []string{"tcheck", "--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName()}
+		setArgs("--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName())
 		main()
 	}()
 
 	// Wait for the main function to end.
 	<-done
-	assert.Equal(t, _exitUnknownUnhealthy, exitCode, "Expected non-zero exit")
+	assert.Equal(t, _exitBadRequest, exitCode, "Expected bad request exit for missing handler")
 }
 
-func TestGetExitCode(t *testing.T) {
-	assert.Equal(t, 5, getExitCode(exitError{5, ""}))
-	assert.Equal(t, 1, getExitCode(errors.New("unknown")))
+func TestHealthCheckMaxLatency(t *testing.T) {
+	server := setupServer(t, func(_ thrift.Context) (ok bool, msg string) {
+		time.Sleep(20 * time.Millisecond)
+		return true, ""
+	})
+	defer server.Close()
+
+	opts := checkOptions{
+		serviceName:   server.ServiceName(),
+		timeout:       time.Second,
+		maxLatency:    5 * time.Millisecond,
+		measureTiming: true,
+	}
+	result, err := healthCheck(server.PeerInfo().HostPort, "", opts)
+	assert.Equal(t, _exitSlow, getExitCode(err), "Expected slow exit for %v", err)
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputText).printResult(result, err)
+	assert.Contains(t, buf.String(), "  handshake: ", "Missing timing")
+	assert.Contains(t, buf.String(), "more than the max latency of 5ms", "Missing slow error")
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printResult(result, err)
+	results := decodeJSONResults(t, &buf)
+	require.Len(t, results, 1, "Expected a single JSON result")
+	assert.Equal(t, "slow", results[0].ErrorClass, "Unexpected error class")
+	require.NotNil(t, results[0].Timing, "Missing timing")
+	assert.True(t, results[0].Timing.CallMs >= 20, "Call time should include the handler")
+
+	opts.maxLatency = time.Second
+	result, err = healthCheck(server.PeerInfo().HostPort, "", opts)
+	assert.NoError(t, err, "Expected health check within the max latency to succeed")
+
+	opts.measureTiming = false
+	result, err = healthCheck(server.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Expected health check within the max latency to succeed")
+	_, timed := result.timing()
+	assert.False(t, timed, "Timing should only be reported if it's measured")
+
+	opts.maxLatency = 5 * time.Millisecond
+	result, err = healthCheck(server.PeerInfo().HostPort, "", opts)
+	assert.Equal(t, _exitSlow, getExitCode(err), "Expected slow exit without measuring timing for %v", err)
+	buf.Reset()
+	newPrinter(&buf, outputText).printResult(result, err)
+	assert.NotContains(t, buf.String(), "  handshake: ", "Timing should only be printed if it's measured")
+	assert.Contains(t, buf.String(), "more than the max latency of 5ms", "Missing slow error")
 }
 
-func TestRemapLocalhost(t *testing.T) {
-	ip, err := tchannel.ListenIP()
-	require.NoError(t, err, "Failed to get ListenIP")
+func TestHealthCheckTiming(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	opts := checkOptions{
+		serviceName:   server.ServiceName(),
+		timeout:       time.Second,
+		measureTiming: true,
+	}
+	result, err := healthCheck(server.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Health check failed")
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputText).printResult(result, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 7, "Expected the process, a line for each phase and the result, got:\n%v", buf.String())
+	assert.True(t, strings.HasPrefix(lines[0], "Process: "+server.PeerInfo().ProcessName), "Unexpected process line %q", lines[0])
+	for i, phase := range []string{"dns", "connect", "handshake", "call", "total"} {
+		line := lines[i+1]
+		assert.True(t, strings.HasPrefix(strings.TrimSpace(line), phase+":"), "Expected %v on line %v, got %q", phase, i+1, line)
+	}
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printResults([]checkResult{result, result}, requirement{kind: requireAll}, nil)
+	results := decodeJSONResults(t, &buf)
+	require.Len(t, results, 3, "Expected a JSON result for each peer and a summary")
+	for _, r := range results[:2] {
+		require.NotNil(t, r.Timing, "Missing timing")
+		assert.True(t, r.Timing.HandshakeMs > 0, "Handshake should be measured")
+		assert.InDelta(t, r.Timing.DNSMs+r.Timing.ConnectMs+r.Timing.HandshakeMs+r.Timing.CallMs, r.Timing.TotalMs, 0.001, "Unexpected total")
+	}
+}
+
+func TestIntegrationMaxLatency(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	server := setupServer(t, func(_ thrift.Context) (ok bool, msg string) {
+		time.Sleep(20 * time.Millisecond)
+		return true, ""
+	})
+	defer server.Close()
+
+	tests := []struct {
+		maxLatency string
+		wantExit   int
+	}{
+		{maxLatency: "1ms", wantExit: _exitSlow},
+		{maxLatency: "1s"},
+		{maxLatency: "-1s", wantExit: _exitUsage},
+	}
+
+	for _, tt := range tests {
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			setArgs("--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName(), "--maxLatency", tt.maxLatency)
+			main()
+		}()
+
+		<-done
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for --maxLatency %v", tt.maxLatency)
+	}
+}
+
+func TestIntegrationExpectProcess(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	tests := []struct {
+		args     []string
+		wantExit int
+	}{
+		{
+			args: []string{"--peer", server.PeerInfo().HostPort, "--expectProcess", server.PeerInfo().ProcessName},
+		},
+		{
+			args:     []string{"--peer", server.PeerInfo().HostPort, "--expectProcess", "populous"},
+			wantExit: _exitWrongProcess,
+		},
+		{
+			args:     []string{"--peer", server.PeerInfo().HostPort, "--expectProcess", "populous", "--mode", "ping"},
+			wantExit: _exitWrongProcess,
+		},
+		{
+			args:     []string{"--hostsFile", writeHostsFile(t, server.PeerInfo().HostPort), "--expectProcess", "populous"},
+			wantExit: _exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			setArgs(append(tt.args, "--serviceName", server.ServiceName())...)
+			main()
+		}()
+
+		<-done
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for %v", tt.args)
+	}
+}
+
+// bodyHandler responds to raw calls with a fixed body.
+type bodyHandler string
+
+func (h bodyHandler) Handle(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+	return &raw.Res{Arg3: []byte(h)}, nil
+}
+
+func (bodyHandler) OnError(ctx context.Context, err error) {}
+
+func TestIntegrationArgScheme(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	server := setupServer(t, nil)
+	defer server.Close()
+	server.Register(raw.Wrap(bodyHandler(`{"ok": false, "message": "draining"}`)), "health")
+	server.Register(raw.Wrap(bodyHandler(`{"ok": true}`)), "status")
+
+	tests := []struct {
+		args     []string
+		wantExit int
+	}{
+		{
+			args:     []string{"--as", "raw", "--endpoint", "health"},
+			wantExit: _exitExplicitUnhealthy,
+		},
+		{
+			args: []string{"--as", "json", "--endpoint", "status"},
+		},
+		{
+			args:     []string{"--as", "json"},
+			wantExit: _exitExplicitUnhealthy,
+		},
+		{
+			args:     []string{"--as", "raw"},
+			wantExit: _exitExplicitUnhealthy,
+		},
+		{
+			args:     []string{"--as", "json", "--endpoint", "Meta::health"},
+			wantExit: _exitBadRequest,
+		},
+		{
+			args:     []string{"--as", "http", "--endpoint", "health"},
+			wantExit: _exitUsage,
+		},
+		{
+			args:     []string{"--endpoint", "health"},
+			wantExit: _exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			setArgs(append(tt.args, "--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName())...)
+			main()
+		}()
+
+		<-done
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for %v", tt.args)
+	}
+}
+
+func TestHeadersFlag(t *testing.T) {
+	var h headers
+	assert.Equal(t, "", h.String(), "Unexpected empty headers")
+	require.NoError(t, h.Set("region=dca"), "Set failed")
+	require.NoError(t, h.Set("auth=a=b"), "Set failed")
+	require.NoError(t, h.Set("empty="), "Set failed")
+	assert.Equal(t, headers{"region": "dca", "auth": "a=b", "empty": ""}, h)
+	assert.Equal(t, "auth=a=b,empty=,region=dca", h.String(), "Headers should be sorted")
+
+	assert.Error(t, h.Set("region"), "Expected header without a value to fail")
+	assert.Error(t, h.Set("=dca"), "Expected header without a key to fail")
+}
+
+func TestNewContext(t *testing.T) {
+	ctx, cancel := newContext(checkOptions{timeout: time.Second, headers: map[string]string{"region": "dca"}})
+	defer cancel()
+
+	assert.Equal(t, map[string]string{"region": "dca"}, ctx.Headers(), "Unexpected headers")
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok, "Context should have a deadline")
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond, "Unexpected deadline")
+
+	assert.Equal(t, _serviceName, checkOptions{}.callerName(), "Unexpected default caller")
+	assert.Equal(t, "health-tester", checkOptions{caller: "health-tester"}.callerName(), "Unexpected caller")
+}
+
+// callHeaders are the transport and application headers of a call.
+type callHeaders struct {
+	caller          string
+	shardKey        string
+	routingKey      string
+	routingDelegate string
+	headers         map[string]string
+}
+
+// headersHandler records the headers of each health check.
+type headersHandler struct {
+	meta.TChanMeta
+	calls chan callHeaders
+}
+
+func (h headersHandler) Health(ctx thrift.Context, hr *meta.HealthRequest) (*meta.HealthStatus, error) {
+	call := tchannel.CurrentCall(ctx)
+	h.calls <- callHeaders{
+		caller:          call.CallerName(),
+		shardKey:        call.ShardKey(),
+		routingKey:      call.RoutingKey(),
+		routingDelegate: call.RoutingDelegate(),
+		headers:         ctx.Headers(),
+	}
+	return &meta.HealthStatus{Ok: true}, nil
+}
+
+func TestIntegrationHeaders(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	server := setupServer(t, nil)
+	defer server.Close()
+
+	calls := make(chan callHeaders, 1)
+	thrift.NewServer(server).Register(meta.NewTChanMetaServer(headersHandler{calls: calls}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		setArgs("--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName(),
+			"--caller", "health-tester", "--shardKey", "shard", "--routingKey", "canary", "--routingDelegate", "relay",
+			"--header", "region=dca", "--header", "team=infra")
+		main()
+	}()
+
+	<-done
+	assert.Equal(t, 0, exitCode, "Unexpected exit code")
+	assert.Equal(t, callHeaders{
+		caller:          "health-tester",
+		shardKey:        "shard",
+		routingKey:      "canary",
+		routingDelegate: "relay",
+		headers:         map[string]string{"region": "dca", "team": "infra"},
+	}, <-calls, "Unexpected headers")
+}
+
+func TestIdentityOutput(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	opts := checkOptions{serviceName: server.ServiceName(), timeout: time.Second}
+	result, err := healthCheck(server.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Health check failed")
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputText).printResult(result, err)
+	assert.Equal(t, "Process: "+server.PeerInfo().ProcessName+" on "+server.PeerInfo().HostPort+
+		", go "+strings.TrimPrefix(runtime.Version(), "go")+" tchannel "+tchannel.VersionInfo+"\nOK\n", buf.String())
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printResult(result, err)
+	results := decodeJSONResults(t, &buf)
+	require.Len(t, results, 1, "Expected a single JSON result")
+	require.NotNil(t, results[0].Identity, "Missing identity")
+	assert.Equal(t, jsonIdentity{
+		HostPort:        server.PeerInfo().HostPort,
+		ProcessName:     server.PeerInfo().ProcessName,
+		Language:        "go",
+		LanguageVersion: strings.TrimPrefix(runtime.Version(), "go"),
+		TChannelVersion: tchannel.VersionInfo,
+	}, *results[0].Identity)
+}
+
+func TestCheckMode(t *testing.T) {
+	var m checkMode
+	assert.Equal(t, "health", m.String(), "Unexpected default")
+	require.NoError(t, m.Set("ping"), "Set failed")
+	assert.Equal(t, modePing, m)
+	assert.Equal(t, "ping", m.String())
+	assert.Error(t, m.Set("pong"), "Expected unknown mode to fail")
+}
+
+func TestIntegrationPing(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	// The server is up, but has no Meta::health handler.
+	noHandler := setupServer(t, nil)
+	defer noHandler.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	ln.Close()
 
 	tests := []struct {
-		hostPort string
-		want     string
+		args     []string
+		wantExit int
 	}{
 		{
-			hostPort: "localhost:1:2",
-			want:     "localhost:1:2",
+			args:     []string{"--peer", noHandler.PeerInfo().HostPort},
+			wantExit: _exitBadRequest,
+		},
+		{
+			args: []string{"--peer", noHandler.PeerInfo().HostPort, "--mode", "ping"},
 		},
 		{
-			hostPort: "1.1.1.1:2",
-			want:     "1.1.1.1:2",
+			args:     []string{"--peer", ln.Addr().String(), "--mode", "ping"},
+			wantExit: _exitConnectionRefused,
 		},
 		{
-			hostPort: "localhost:2",
-			want:     ip.String() + ":2",
+			args:     []string{"--peer", noHandler.PeerInfo().HostPort + "," + ln.Addr().String(), "--mode", "ping", "--require", "any"},
+			wantExit: 0,
+		},
+		{
+			args:     []string{"--hostsFile", writeHostsFile(t, noHandler.PeerInfo().HostPort), "--mode", "ping"},
+			wantExit: _exitUsage,
 		},
 	}
 
 	for _, tt := range tests {
-		assert.Equal(t, tt.want, remapLocalhost(tt.hostPort), "Remap %q", tt.hostPort)
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			setArgs(append(tt.args, "--serviceName", noHandler.ServiceName())...)
+			main()
+		}()
+
+		<-done
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for %v", tt.args)
 	}
 }
+
+func TestPingOutput(t *testing.T) {
+	server := setupServer(t, nil)
+	defer server.Close()
+
+	opts := checkOptions{serviceName: server.ServiceName(), timeout: time.Second, mode: modePing}
+	result, err := healthCheck(server.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Ping failed")
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputText).printResult(result, err)
+	assert.Contains(t, buf.String(), "  ping:", "Missing ping time")
+	assert.NotContains(t, buf.String(), "call:", "Ping should not report a call")
+
+	buf.Reset()
+	newPrinter(&buf, outputJSON).printResult(result, err)
+	results := decodeJSONResults(t, &buf)
+	require.Len(t, results, 1, "Expected a single JSON result")
+	require.NotNil(t, results[0].Timing, "Missing timing")
+	assert.True(t, results[0].Timing.PingMs > 0, "Missing ping time")
+	assert.Zero(t, results[0].Timing.CallMs, "Ping should not report a call")
+}
+
+// healthTypeHandler is only ready for traffic if ready is set, and returns an
+// error for traffic health checks if failTraffic is set.
+type healthTypeHandler struct {
+	meta.TChanMeta
+
+	ready       bool
+	failTraffic bool
+}
+
+func (h healthTypeHandler) Health(_ thrift.Context, hr *meta.HealthRequest) (*meta.HealthStatus, error) {
+	if hr != nil && hr.GetType() == meta.HealthRequestType_TRAFFIC && h.failTraffic {
+		return nil, errors.New("readiness check failed")
+	}
+	if hr != nil && hr.GetType() == meta.HealthRequestType_TRAFFIC && !h.ready {
+		msg := "warming up"
+		return &meta.HealthStatus{Ok: false, Message: &msg}, nil
+	}
+	return &meta.HealthStatus{Ok: true}, nil
+}
+
+func TestHealthCheckType(t *testing.T) {
+	tests := []struct {
+		msg        string
+		handler    healthTypeHandler
+		healthType string
+		wantExit   int
+	}{
+		{
+			msg:     "no health type",
+			handler: healthTypeHandler{},
+		},
+		{
+			msg:        "process health type",
+			handler:    healthTypeHandler{},
+			healthType: "process",
+		},
+		{
+			msg:        "traffic health type when not ready",
+			handler:    healthTypeHandler{},
+			healthType: "traffic",
+			wantExit:   _exitExplicitUnhealthy,
+		},
+		{
+			msg:        "traffic health type when ready",
+			handler:    healthTypeHandler{ready: true},
+			healthType: "traffic",
+		},
+		{
+			// An error from the handler isn't a rejected HealthRequest, so
+			// the health check isn't retried without the type.
+			msg:        "traffic health type error",
+			handler:    healthTypeHandler{failTraffic: true},
+			healthType: "traffic",
+			wantExit:   _exitUnknownUnhealthy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			server := setupServer(t, nil)
+			defer server.Close()
+			thrift.NewServer(server).Register(meta.NewTChanMetaServer(tt.handler))
+
+			var healthType healthTypeFlag
+			if tt.healthType != "" {
+				require.NoError(t, healthType.Set(tt.healthType), "Failed to set health type")
+			}
+
+			opts := checkOptions{serviceName: server.ServiceName(), timeout: time.Second, healthType: healthType.value}
+			result, err := healthCheck(server.PeerInfo().HostPort, "", opts)
+			assert.False(t, result.HealthTypeIgnored, "HealthRequest should not be rejected")
+			if tt.wantExit > 0 {
+				require.Error(t, err, "Expected health check to fail")
+				assert.Equal(t, tt.wantExit, getExitCode(err), "Unexpected exit code")
+				return
+			}
+			require.NoError(t, err, "Expected health check to succeed")
+		})
+	}
+}
+
+func TestHealthCheckTypeDefaultHandler(t *testing.T) {
+	// The default Meta handler in tchannel-go ignores the HealthRequest.
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	traffic := meta.HealthRequestType_TRAFFIC
+	opts := checkOptions{serviceName: server.ServiceName(), timeout: time.Second, healthType: &traffic}
+	result, err := healthCheck(server.PeerInfo().HostPort, "", opts)
+	require.NoError(t, err, "Expected health check to succeed")
+	assert.False(t, result.HealthTypeIgnored, "HealthRequest should not be rejected")
+}
+
+func TestHealthTypeFlag(t *testing.T) {
+	var f healthTypeFlag
+	assert.Equal(t, "", f.String())
+
+	require.NoError(t, f.Set("traffic"))
+	assert.Equal(t, meta.HealthRequestType_TRAFFIC, *f.value)
+	assert.Equal(t, "traffic", f.String())
+
+	require.NoError(t, f.Set("PROCESS"))
+	assert.Equal(t, meta.HealthRequestType_PROCESS, *f.value)
+	assert.Equal(t, "process", f.String())
+
+	assert.Error(t, f.Set("liveness"), "Expected unknown health type to fail")
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantCmd  string
+		wantArgs []string
+	}{
+		{args: nil, wantArgs: nil},
+		{args: []string{"--peer", "1.1.1.1:1"}, wantArgs: []string{"--peer", "1.1.1.1:1"}},
+		{args: []string{"version-info", "--peer", "1.1.1.1:1"}, wantCmd: "version-info", wantArgs: []string{"--peer", "1.1.1.1:1"}},
+	}
+
+	for _, tt := range tests {
+		cmd, args := parseCommand(tt.args)
+		assert.Equal(t, tt.wantCmd, cmd, "Unexpected command for %v", tt.args)
+		assert.Equal(t, tt.wantArgs, args, "Unexpected args for %v", tt.args)
+	}
+}
+
+func TestIntegrationUnknownCommand(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		setArgs("bogus", "--peer", "127.0.0.1:1", "--serviceName", "svc")
+		main()
+	}()
+
+	<-done
+	assert.Equal(t, _exitUsage, exitCode, "Expected usage exit code")
+}
+
+func TestGetExitCode(t *testing.T) {
+	assert.Equal(t, 5, getExitCode(exitError{5, ""}))
+	assert.Equal(t, 1, getExitCode(errors.New("unknown")))
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchecktest
+
+import (
+	"testing"
+
+	"github.com/uber/tcheck/health"
+)
+
+// AssertHealthy reports an error if the health check didn't succeed with a
+// healthy result.
+func AssertHealthy(t testing.TB, result health.Result, err error) bool {
+	if err != nil {
+		t.Errorf("expected %v to be healthy, got error: %v", result.ServiceName, err)
+		return false
+	}
+	if !result.Healthy {
+		t.Errorf("expected %v to be healthy, got unhealthy: %q", result.ServiceName, result.Message)
+		return false
+	}
+	return true
+}
+
+// AssertUnhealthy reports an error if the peer didn't respond to the health
+// check reporting itself unhealthy with message.
+func AssertUnhealthy(t testing.TB, result health.Result, err error, message string) bool {
+	if _, ok := err.(*health.UnhealthyError); !ok {
+		t.Errorf("expected %v to report itself unhealthy, got error: %v", result.ServiceName, err)
+		return false
+	}
+	if result.Message != message {
+		t.Errorf("expected unhealthy message %q, got %q", message, result.Message)
+		return false
+	}
+	return true
+}
+
+// AssertErrorClass reports an error if the health check didn't fail with an
+// error of the given class.
+func AssertErrorClass(t testing.TB, err error, class health.ErrorClass) bool {
+	if _, ok := err.(*health.CallError); !ok {
+		t.Errorf("expected health check to fail with %v, got: %v", class, err)
+		return false
+	}
+	if got := health.Classify(err); got != class {
+		t.Errorf("expected health check to fail with %v, got %v: %v", class, got, err)
+		return false
+	}
+	return true
+}
+
+// AssertAttempts reports an error if the health check wasn't made in n
+// attempts.
+func AssertAttempts(t testing.TB, result health.Result, n int) bool {
+	if len(result.Attempts) != n {
+		t.Errorf("expected %v attempts, got %v", n, len(result.Attempts))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchecktest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/uber/tcheck/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go"
+)
+
+// recordingT records errors instead of failing the test.
+type recordingT struct {
+	testing.TB
+
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	busy := &health.CallError{Class: health.ClassBusy, Err: tchannel.ErrServerBusy}
+	unhealthy := health.Result{ServiceName: "svc", Message: "draining"}
+
+	tests := []struct {
+		msg    string
+		assert func(t testing.TB) bool
+		want   bool
+	}{
+		{
+			msg:    "healthy",
+			assert: func(t testing.TB) bool { return AssertHealthy(t, health.Result{Healthy: true}, nil) },
+			want:   true,
+		},
+		{
+			msg:    "healthy with error",
+			assert: func(t testing.TB) bool { return AssertHealthy(t, health.Result{}, busy) },
+		},
+		{
+			msg:    "healthy when unhealthy",
+			assert: func(t testing.TB) bool { return AssertHealthy(t, unhealthy, nil) },
+		},
+		{
+			msg: "unhealthy",
+			assert: func(t testing.TB) bool {
+				return AssertUnhealthy(t, unhealthy, &health.UnhealthyError{Message: "draining"}, "draining")
+			},
+			want: true,
+		},
+		{
+			msg: "unhealthy with wrong message",
+			assert: func(t testing.TB) bool {
+				return AssertUnhealthy(t, unhealthy, &health.UnhealthyError{Message: "draining"}, "starting")
+			},
+		},
+		{
+			msg:    "unhealthy with error",
+			assert: func(t testing.TB) bool { return AssertUnhealthy(t, unhealthy, busy, "draining") },
+		},
+		{
+			msg:    "error class",
+			assert: func(t testing.TB) bool { return AssertErrorClass(t, busy, health.ClassBusy) },
+			want:   true,
+		},
+		{
+			msg:    "wrong error class",
+			assert: func(t testing.TB) bool { return AssertErrorClass(t, busy, health.ClassTimeout) },
+		},
+		{
+			msg:    "error class without a call error",
+			assert: func(t testing.TB) bool { return AssertErrorClass(t, errors.New("failed"), health.ClassUnknown) },
+		},
+		{
+			msg: "attempts",
+			assert: func(t testing.TB) bool {
+				return AssertAttempts(t, health.Result{Attempts: make([]health.Attempt, 2)}, 2)
+			},
+			want: true,
+		},
+		{
+			msg:    "wrong attempts",
+			assert: func(t testing.TB) bool { return AssertAttempts(t, health.Result{}, 1) },
+		},
+	}
+
+	for _, tt := range tests {
+		rt := &recordingT{TB: t}
+		assert.Equal(t, tt.want, tt.assert(rt), "%v: unexpected result", tt.msg)
+		assert.Equal(t, !tt.want, len(rt.errors) > 0, "%v: unexpected errors %v", tt.msg, rt.errors)
+	}
+}
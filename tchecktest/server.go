@@ -0,0 +1,285 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package tchecktest provides fake Meta servers and assertions for testing
+// health checks made using the health package.
+//
+// A Server responds to Meta::health with a configurable response, which can
+// be scripted to change on each call:
+//
+//	server := tchecktest.NewServer(t, "keyvalue")
+//	defer server.Close()
+//
+//	server.Script(
+//		tchecktest.Unhealthy("starting"),
+//		tchecktest.Response{Err: tchannel.ErrServerBusy},
+//	)
+//	server.SetResponse(tchecktest.Healthy())
+package tchecktest
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+// Response is the response to a single Meta::health call.
+type Response struct {
+	Healthy bool
+	Message string
+
+	// Latency is added before responding.
+	Latency time.Duration
+
+	// Err is returned to the caller instead of a health status, such as
+	// tchannel.ErrServerBusy.
+	Err error
+
+	// Drop closes all of the server's connections instead of responding.
+	Drop bool
+}
+
+// Healthy returns a healthy response.
+func Healthy() Response {
+	return Response{Healthy: true}
+}
+
+// Unhealthy returns a response that reports the service is not healthy.
+func Unhealthy(message string) Response {
+	return Response{Message: message}
+}
+
+// Server is a fake Meta server. It's safe to change its responses while
+// health checks are in flight.
+type Server struct {
+	ch *tchannel.Channel
+	l  *trackingListener
+
+	mu       sync.Mutex
+	response Response
+	script   []Response
+	pos      int
+	loop     bool
+	latency  time.Duration
+	idl      string
+	calls    int
+}
+
+// NewServer returns a Server for serviceName listening on a free local port,
+// which responds healthy until changed.
+func NewServer(t testing.TB, serviceName string) *Server {
+	ch, err := tchannel.NewChannel(serviceName, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		ch.Close()
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &Server{ch: ch, l: &trackingListener{Listener: ln}, response: Healthy()}
+	thrift.NewServer(ch).Register(meta.NewTChanMetaServer(s))
+	if err := ch.Serve(s.l); err != nil {
+		ch.Close()
+		t.Fatalf("failed to serve: %v", err)
+	}
+	return s
+}
+
+// HostPort returns the host:port the server is listening on.
+func (s *Server) HostPort() string {
+	return s.ch.PeerInfo().HostPort
+}
+
+// Channel returns the server's channel, which can be used to register other
+// handlers.
+func (s *Server) Channel() *tchannel.Channel {
+	return s.ch
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.ch.Close()
+}
+
+// SetResponse sets the response for calls once any scripted responses have
+// been used.
+func (s *Server) SetResponse(r Response) {
+	s.mu.Lock()
+	s.response = r
+	s.mu.Unlock()
+}
+
+// Script sets responses that are used in order for the next calls, before
+// the response set by SetResponse.
+func (s *Server) Script(responses ...Response) {
+	s.mu.Lock()
+	s.script, s.pos, s.loop = responses, 0, false
+	s.mu.Unlock()
+}
+
+// Loop sets responses that are used in order for every call, repeating from
+// the first once the last is used.
+func (s *Server) Loop(responses ...Response) {
+	s.mu.Lock()
+	s.script, s.pos, s.loop = responses, 0, true
+	s.mu.Unlock()
+}
+
+// Flap alternates between every healthy and every unhealthy responses with
+// message, starting with healthy.
+func (s *Server) Flap(every int, message string) {
+	var responses []Response
+	for i := 0; i < every; i++ {
+		responses = append(responses, Healthy())
+	}
+	for i := 0; i < every; i++ {
+		responses = append(responses, Unhealthy(message))
+	}
+	s.Loop(responses...)
+}
+
+// SetLatency adds latency before every response, in addition to the latency
+// of the response itself.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	s.latency = latency
+	s.mu.Unlock()
+}
+
+// SetIDL sets the IDL returned by Meta::thriftIDL.
+func (s *Server) SetIDL(idl string) {
+	s.mu.Lock()
+	s.idl = idl
+	s.mu.Unlock()
+}
+
+// Calls returns the number of Meta::health calls received.
+func (s *Server) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// DropConnections closes all of the server's connections. The server keeps
+// listening, so callers can reconnect.
+func (s *Server) DropConnections() {
+	s.l.closeConns()
+}
+
+// next returns the response for the next call.
+func (s *Server) next() Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.response
+	if len(s.script) > 0 {
+		r = s.script[s.pos]
+		if s.pos++; s.pos == len(s.script) {
+			s.pos = 0
+			if !s.loop {
+				s.script = nil
+			}
+		}
+	}
+	s.calls++
+	r.Latency += s.latency
+	return r
+}
+
+// Health implements meta.TChanMeta using the next response.
+func (s *Server) Health(ctx thrift.Context, _ *meta.HealthRequest) (*meta.HealthStatus, error) {
+	r := s.next()
+	time.Sleep(r.Latency)
+
+	if r.Drop {
+		s.DropConnections()
+		return nil, tchannel.NewSystemError(tchannel.ErrCodeNetwork, "connection dropped")
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	status := &meta.HealthStatus{Ok: r.Healthy}
+	if r.Message != "" {
+		status.Message = &r.Message
+	}
+	return status, nil
+}
+
+// ThriftIDL implements meta.TChanMeta, returning the IDL set by SetIDL.
+func (s *Server) ThriftIDL(ctx thrift.Context) (string, error) {
+	s.mu.Lock()
+	idl := s.idl
+	s.mu.Unlock()
+
+	if idl == "" {
+		return "", fmt.Errorf("no IDL set")
+	}
+	return idl, nil
+}
+
+// VersionInfo implements meta.TChanMeta.
+func (s *Server) VersionInfo(ctx thrift.Context) (*meta.VersionInfo, error) {
+	return &meta.VersionInfo{
+		Language:        "go",
+		LanguageVersion: runtime.Version(),
+		Version:         tchannel.VersionInfo,
+	}, nil
+}
+
+// trackingListener tracks accepted connections, so they can be closed.
+type trackingListener struct {
+	net.Listener
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) closeConns() {
+	l.mu.Lock()
+	conns := l.conns
+	l.conns = nil
+	l.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
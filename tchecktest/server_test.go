@@ -0,0 +1,145 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchecktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
+)
+
+func newChecker(t *testing.T, timeout time.Duration) *health.Checker {
+	checker, err := health.NewChecker(health.Options{ServiceName: "svc", Timeout: timeout})
+	require.NoError(t, err, "Failed to create checker")
+	return checker
+}
+
+func TestServerResponses(t *testing.T) {
+	server := NewServer(t, "svc")
+	defer server.Close()
+
+	checker := newChecker(t, time.Second)
+	defer checker.Close()
+
+	check := func() (health.Result, error) {
+		return checker.Check(context.Background(), server.HostPort())
+	}
+
+	result, err := check()
+	AssertHealthy(t, result, err)
+
+	server.SetResponse(Unhealthy("draining"))
+	result, err = check()
+	AssertUnhealthy(t, result, err, "draining")
+
+	server.Script(Healthy(), Response{Err: tchannel.ErrServerBusy})
+	result, err = check()
+	AssertHealthy(t, result, err)
+	_, err = check()
+	AssertErrorClass(t, err, health.ClassBusy)
+
+	// Once the script is used, the response set by SetResponse is used.
+	result, err = check()
+	AssertUnhealthy(t, result, err, "draining")
+	assert.Equal(t, 5, server.Calls(), "Unexpected number of calls")
+}
+
+func TestServerFlap(t *testing.T) {
+	server := NewServer(t, "svc")
+	defer server.Close()
+
+	checker := newChecker(t, time.Second)
+	defer checker.Close()
+
+	server.Flap(2, "flapping")
+	var got []bool
+	for i := 0; i < 6; i++ {
+		result, _ := checker.Check(context.Background(), server.HostPort())
+		got = append(got, result.Healthy)
+	}
+	assert.Equal(t, []bool{true, true, false, false, true, true}, got, "Unexpected results")
+}
+
+func TestServerLatency(t *testing.T) {
+	server := NewServer(t, "svc")
+	defer server.Close()
+
+	checker := newChecker(t, 20*time.Millisecond)
+	defer checker.Close()
+
+	server.SetLatency(100 * time.Millisecond)
+	_, err := checker.Check(context.Background(), server.HostPort())
+	AssertErrorClass(t, err, health.ClassTimeout)
+
+	server.SetLatency(0)
+	server.SetResponse(Response{Healthy: true, Latency: 100 * time.Millisecond})
+	_, err = checker.Check(context.Background(), server.HostPort())
+	AssertErrorClass(t, err, health.ClassTimeout)
+}
+
+func TestServerDrop(t *testing.T) {
+	server := NewServer(t, "svc")
+	defer server.Close()
+
+	checker := newChecker(t, time.Second)
+	defer checker.Close()
+
+	server.Script(Response{Drop: true})
+	_, err := checker.Check(context.Background(), server.HostPort())
+	AssertErrorClass(t, err, health.ClassNetwork)
+
+	// The server keeps listening after dropping connections.
+	result, err := checker.Check(context.Background(), server.HostPort())
+	AssertHealthy(t, result, err)
+}
+
+func TestServerIDL(t *testing.T) {
+	server := NewServer(t, "svc")
+	defer server.Close()
+
+	ch, err := tchannel.NewChannel("tcheck", nil)
+	require.NoError(t, err, "Failed to create channel")
+	defer ch.Close()
+
+	client := meta.NewTChanMetaClient(thrift.NewClient(ch, "svc", &thrift.ClientOptions{HostPort: server.HostPort()}))
+	ctx, cancel := thrift.NewContext(time.Second)
+	defer cancel()
+
+	_, err = client.ThriftIDL(ctx)
+	assert.Error(t, err, "Expected an error without an IDL")
+
+	server.SetIDL("service KeyValue {}")
+	idl, err := client.ThriftIDL(ctx)
+	require.NoError(t, err, "ThriftIDL failed")
+	assert.Equal(t, "service KeyValue {}", idl, "Unexpected IDL")
+
+	info, err := client.VersionInfo(ctx)
+	require.NoError(t, err, "VersionInfo failed")
+	assert.Equal(t, tchannel.VersionInfo, info.Version, "Unexpected version")
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/uber/tcheck/gen-go/meta"
+	"github.com/uber/tcheck/health"
+
+	"github.com/uber/tchannel-go/thrift"
+)
+
+// versionResult is the result of calling Meta::versionInfo on a single peer.
+type versionResult struct {
+	peer         string
+	resolvedPeer string
+	relay        string
+	serviceName  string
+	info         *meta.VersionInfo
+	err          error
+}
+
+// target returns a description of where the call was sent.
+func (r versionResult) target() string {
+	if r.peer != "" {
+		return r.peer
+	}
+	return fmt.Sprintf("%v via %v", r.serviceName, r.relay)
+}
+
+func (r versionResult) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("%v Error: %v", r.target(), r.err)
+	}
+	return fmt.Sprintf("%v %v %v tchannel %v",
+		r.target(), r.info.Language, r.info.LanguageVersion, r.info.Version)
+}
+
+// jsonVersionResult is the record written for each peer with --output json.
+type jsonVersionResult struct {
+	Peer            string `json:"peer,omitempty"`
+	ResolvedPeer    string `json:"resolvedPeer,omitempty"`
+	Relay           string `json:"relay,omitempty"`
+	Service         string `json:"service"`
+	Language        string `json:"language,omitempty"`
+	LanguageVersion string `json:"languageVersion,omitempty"`
+	Version         string `json:"version,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+func newJSONVersionResult(r versionResult) jsonVersionResult {
+	jr := jsonVersionResult{
+		Peer:         r.peer,
+		ResolvedPeer: r.resolvedPeer,
+		Relay:        r.relay,
+		Service:      r.serviceName,
+	}
+	if r.err != nil {
+		jr.Error = r.err.Error()
+	}
+	if r.info != nil {
+		jr.Language = r.info.Language
+		jr.LanguageVersion = r.info.LanguageVersion
+		jr.Version = r.info.Version
+	}
+	return jr
+}
+
+func runVersionInfo(p printer, opts checkOptions) {
+	results, err := versionInfo(peers, *hostsFile, opts, *concurrency)
+	p.printVersions(results, err)
+	exit(err)
+}
+
+// versionInfo calls Meta::versionInfo on the service at each peer, or through
+// the Hyperbahn relays listed in hostsFile if there are no peers.
+func versionInfo(peers []string, hostsFile string, opts checkOptions, concurrency int) ([]versionResult, error) {
+	if len(peers) == 0 && hostsFile == "" {
+		return nil, exitError{_exitUsage, "Must specify a peer or a Hyperbahn hosts file to query"}
+	}
+	if err := validateArgs(opts); err != nil {
+		return nil, err
+	}
+	if concurrency <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive concurrency"}
+	}
+
+	if len(peers) == 0 {
+		ch, err := newHyperbahnChannel(opts, hostsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer ch.Close()
+
+		result := callVersionInfo(thrift.NewClient(ch, opts.serviceName, nil), opts)
+		result.relay = health.ConnectedPeer(ch)
+		return []versionResult{result}, versionErr([]versionResult{result})
+	}
+
+	ch, err := newChannel(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	results := make([]versionResult, len(peers))
+	forEachPeer(peers, concurrency, func(i int, peer string) {
+		resolvedPeer := health.RemapLocalhost(peer)
+		client := thrift.NewClient(ch, opts.serviceName, &thrift.ClientOptions{HostPort: resolvedPeer})
+		results[i] = callVersionInfo(client, opts)
+		results[i].peer = peer
+		results[i].resolvedPeer = resolvedPeer
+	})
+	return results, versionErr(results)
+}
+
+func callVersionInfo(thriftClient thrift.TChanClient, opts checkOptions) versionResult {
+	ctx, cancel := newContext(opts)
+	defer cancel()
+
+	result := versionResult{serviceName: opts.serviceName}
+	result.info, result.err = meta.NewTChanMetaClient(thriftClient).VersionInfo(ctx)
+	return result
+}
+
+// versionErr returns an exitError if any peer failed to return its version.
+// If all the peers failed in the same way, such as timing out, the exit code
+// reflects that.
+func versionErr(results []versionResult) error {
+	var failed int
+	code := 0
+	for _, r := range results {
+		if r.err == nil {
+			continue
+		}
+
+		failed++
+		switch peerCode := errExitCode(r.err); {
+		case code == 0:
+			code = peerCode
+		case code != peerCode:
+			code = _exitUnknownUnhealthy
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return exitError{code, fmt.Sprintf("Failed to get version info from %v of %v peers", failed, len(results))}
+}
+
+// printVersions prints the results of Meta::versionInfo calls, where err is
+// the error returned by versionInfo.
+func (p printer) printVersions(results []versionResult, err error) {
+	if p.format == outputJSON {
+		for _, r := range results {
+			p.writeJSON(newJSONVersionResult(r))
+		}
+		if len(results) == 0 && err != nil {
+			p.writeJSON(jsonVersionResult{Error: err.Error()})
+		}
+		return
+	}
+
+	for _, r := range results {
+		fmt.Fprintln(p.w, r)
+	}
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+	}
+}
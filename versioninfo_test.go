@@ -0,0 +1,131 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func TestVersionInfo(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	noHandler := setupServer(t, nil)
+	defer noHandler.Close()
+
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+	peers := []string{server.PeerInfo().HostPort, noHandler.PeerInfo().HostPort}
+	results, err := versionInfo(peers, "", opts, 2)
+	require.Error(t, err, "Expected peer without a handler to fail")
+	assert.Equal(t, _exitBadRequest, getExitCode(err), "Unexpected exit code")
+	require.Len(t, results, 2, "Expected a result per peer")
+
+	assert.NoError(t, results[0].err, "Unexpected error from default Meta handler")
+	assert.Equal(t, "go", results[0].info.Language, "Unexpected language")
+	assert.Equal(t, tchannel.VersionInfo, results[0].info.Version, "Unexpected library version")
+	assert.Equal(t, peers[0], results[0].resolvedPeer, "Unexpected resolved peer")
+	assert.Contains(t, results[0].String(), "tchannel "+tchannel.VersionInfo, "Unexpected String")
+
+	assert.Error(t, results[1].err, "Expected error from peer without a handler")
+	assert.Contains(t, results[1].String(), "ErrCodeBadRequest", "Unexpected String")
+}
+
+func TestVersionErr(t *testing.T) {
+	busy := versionResult{err: tchannel.ErrServerBusy}
+	timeout := versionResult{err: tchannel.ErrTimeout}
+	ok := versionResult{}
+
+	tests := []struct {
+		msg      string
+		results  []versionResult
+		wantCode int
+	}{
+		{"all ok", []versionResult{ok, ok}, 0},
+		{"one busy", []versionResult{ok, busy}, _exitBusy},
+		{"all timed out", []versionResult{timeout, timeout}, _exitTimeout},
+		{"different errors", []versionResult{busy, timeout}, _exitUnknownUnhealthy},
+	}
+
+	for _, tt := range tests {
+		err := versionErr(tt.results)
+		if tt.wantCode == 0 {
+			assert.NoError(t, err, "%v: unexpected error", tt.msg)
+			continue
+		}
+		assert.Equal(t, tt.wantCode, getExitCode(err), "%v: unexpected exit code", tt.msg)
+	}
+}
+
+func TestVersionInfoHyperbahn(t *testing.T) {
+	relay := setupServer(t, healthOk)
+	defer relay.Close()
+
+	hostsFile := writeHostsFile(t, relay.PeerInfo().HostPort)
+	defer os.Remove(hostsFile)
+
+	results, err := versionInfo(nil, hostsFile, checkOptions{serviceName: "svc", timeout: time.Second}, 1)
+	require.NoError(t, err, "versionInfo through Hyperbahn failed")
+	require.Len(t, results, 1, "Expected a single result")
+	assert.Equal(t, relay.PeerInfo().HostPort, results[0].relay, "Unexpected relay")
+	assert.Equal(t, "svc via "+relay.PeerInfo().HostPort, results[0].target(), "Unexpected target")
+}
+
+func TestVersionInfoBadArgs(t *testing.T) {
+	opts := checkOptions{serviceName: "svc", timeout: time.Second}
+
+	_, err := versionInfo(nil, "", opts, 1)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing peer to fail")
+
+	_, err = versionInfo([]string{"127.0.0.1:1"}, "", checkOptions{timeout: time.Second}, 1)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected missing service to fail")
+
+	_, err = versionInfo([]string{"127.0.0.1:1"}, "", opts, 0)
+	assert.Equal(t, _exitUsage, getExitCode(err), "Expected invalid concurrency to fail")
+}
+
+func TestPrintVersionsJSON(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	peers := []string{server.PeerInfo().HostPort}
+	results, err := versionInfo(peers, "", checkOptions{serviceName: "svc", timeout: time.Second}, 1)
+	require.NoError(t, err, "versionInfo failed")
+
+	var buf bytes.Buffer
+	newPrinter(&buf, outputJSON).printVersions(results, err)
+	assert.Contains(t, buf.String(), `"language":"go"`, "Missing language")
+	assert.Contains(t, buf.String(), `"version":"`+tchannel.VersionInfo+`"`, "Missing version")
+}
+
+func TestIntegrationVersionInfo(t *testing.T) {
+	server := setupServer(t, healthOk)
+	defer server.Close()
+
+	setArgs("version-info", "--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName())
+	main()
+}
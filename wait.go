@@ -0,0 +1,224 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uber/tcheck/health"
+)
+
+// _initialWaitBackoff is the delay before the second health check when
+// waiting, which doubles after each check up to --interval.
+const _initialWaitBackoff = 100 * time.Millisecond
+
+// waitCondition is the state to wait for with --waitFor.
+type waitCondition int
+
+const (
+	waitNone waitCondition = iota
+	waitHealthy
+	waitUnhealthy
+	waitGone
+)
+
+func (c *waitCondition) String() string {
+	switch *c {
+	case waitHealthy:
+		return "healthy"
+	case waitUnhealthy:
+		return "unhealthy"
+	case waitGone:
+		return "gone"
+	default:
+		return ""
+	}
+}
+
+func (c *waitCondition) Set(v string) error {
+	switch v {
+	case "healthy":
+		*c = waitHealthy
+	case "unhealthy":
+		*c = waitUnhealthy
+	case "gone":
+		*c = waitGone
+	default:
+		return fmt.Errorf("unknown wait condition %q, must be healthy, unhealthy or gone", v)
+	}
+	return nil
+}
+
+// met returns whether the result of a health check satisfies the condition.
+// A peer is unhealthy if the health check fails for any reason, and gone if
+// it can't be connected to.
+func (c waitCondition) met(r checkResult) bool {
+	err := r.exitErr()
+	switch c {
+	case waitHealthy:
+		return err == nil
+	case waitUnhealthy:
+		return err != nil
+	case waitGone:
+		switch getExitCode(err) {
+		case _exitConnectionRefused, _exitDNSFailure, _exitNetworkError:
+			return true
+		}
+	}
+	return false
+}
+
+// waitOptions are the options for waiting for a peer to reach a state.
+type waitOptions struct {
+	condition   waitCondition
+	maxWait     time.Duration
+	consecutive int
+
+	// maxBackoff is the maximum delay between health checks.
+	maxBackoff time.Duration
+}
+
+// waitResult is the outcome of waiting for a peer to reach a state.
+type waitResult struct {
+	condition waitCondition
+	last      checkResult
+	checks    int
+	elapsed   time.Duration
+}
+
+// waitFor health checks until condition is met by consecutive checks, or
+// maxWait has passed, backing off between checks.
+func waitFor(check func() checkResult, opts waitOptions) (waitResult, error) {
+	result := waitResult{condition: opts.condition}
+	started := time.Now()
+	backoff := _initialWaitBackoff
+	if backoff > opts.maxBackoff {
+		backoff = opts.maxBackoff
+	}
+
+	var met int
+	for {
+		result.last = check()
+		result.checks++
+		result.elapsed = time.Since(started)
+
+		if opts.condition.met(result.last) {
+			met++
+		} else {
+			met = 0
+		}
+		if met >= opts.consecutive {
+			return result, nil
+		}
+
+		remaining := opts.maxWait - result.elapsed
+		if remaining <= 0 {
+			return result, exitError{_exitWaitTimeout, fmt.Sprintf("NOT OK %v not %v after %v and %v checks\nLast result: %v\n",
+				result.last.target(), opts.condition.String(), result.elapsed, result.checks, result.last)}
+		}
+
+		sleep := backoff
+		if sleep > remaining {
+			sleep = remaining
+		}
+		time.Sleep(sleep)
+
+		if backoff *= 2; backoff > opts.maxBackoff {
+			backoff = opts.maxBackoff
+		}
+	}
+}
+
+func runWait(p printer, opts checkOptions) {
+	var peer string
+	if len(peers) == 1 {
+		peer = peers[0]
+	}
+
+	result := waitResult{
+		condition: waitUntil,
+		last:      checkResult{Result: health.Result{Peer: peer, ServiceName: opts.serviceName}},
+	}
+	c, err := newWaitChecker(peer, opts)
+	if err == nil {
+		result, err = waitFor(c.check, waitOptions{
+			condition:   waitUntil,
+			maxWait:     *maxWait,
+			consecutive: *consecutive,
+			maxBackoff:  *interval,
+		})
+		c.close()
+	}
+
+	p.printWait(result, err)
+	exit(err)
+}
+
+// newWaitChecker validates the flags for --waitFor, and returns a checker for
+// the peer, or the service through Hyperbahn if peer is empty.
+func newWaitChecker(peer string, opts checkOptions) (*checker, error) {
+	if len(peers) > 1 {
+		return nil, exitError{_exitUsage, "Must specify a single peer to wait for"}
+	}
+	if *maxWait <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive max wait"}
+	}
+	if *consecutive <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive number of consecutive checks"}
+	}
+	if *interval <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive interval"}
+	}
+	return newChecker(peer, *hostsFile, opts)
+}
+
+// jsonWaitResult is the record written for --waitFor with --output json.
+type jsonWaitResult struct {
+	jsonResult
+
+	WaitFor   string  `json:"waitFor"`
+	Checks    int     `json:"checks"`
+	ElapsedMs float64 `json:"elapsedMs"`
+}
+
+// printWait prints the outcome of waiting, where err is the error returned
+// by waitFor.
+func (p printer) printWait(r waitResult, err error) {
+	if p.format == outputJSON {
+		p.writeJSON(jsonWaitResult{
+			jsonResult: newJSONResult(r.last, err),
+			WaitFor:    r.condition.String(),
+			Checks:     r.checks,
+			ElapsedMs:  r.elapsed.Seconds() * 1000,
+		})
+		return
+	}
+
+	if r.last.Relay != "" {
+		fmt.Fprintln(p.w, "Relay:", r.last.Relay)
+	}
+	if err != nil {
+		fmt.Fprintln(p.w, err)
+		return
+	}
+	fmt.Fprintf(p.w, "OK %v %v after %v and %v checks\n", r.last.target(), r.condition.String(), r.elapsed, r.checks)
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+func TestWaitCondition(t *testing.T) {
+	for _, v := range []string{"healthy", "unhealthy", "gone"} {
+		var c waitCondition
+		require.NoError(t, c.Set(v), "Set(%q) failed", v)
+		assert.Equal(t, v, c.String(), "Unexpected String for %q", v)
+	}
+
+	var c waitCondition
+	assert.Error(t, c.Set("ready"), "Expected unknown condition to fail")
+}
+
+func TestWaitConditionMet(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+
+	tests := []struct {
+		msg       string
+		result    checkResult
+		healthy   bool
+		unhealthy bool
+		gone      bool
+	}{
+		{
+			msg:     "healthy",
+			result:  statusResult(true, ""),
+			healthy: true,
+		},
+		{
+			msg:       "explicitly unhealthy",
+			result:    statusResult(false, "draining"),
+			unhealthy: true,
+		},
+		{
+			msg:       "timeout",
+			result:    checkResult{err: tchannel.ErrTimeout},
+			unhealthy: true,
+		},
+		{
+			msg:       "connection refused",
+			result:    checkResult{err: refused},
+			unhealthy: true,
+			gone:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.healthy, waitHealthy.met(tt.result), "%v: unexpected healthy", tt.msg)
+		assert.Equal(t, tt.unhealthy, waitUnhealthy.met(tt.result), "%v: unexpected unhealthy", tt.msg)
+		assert.Equal(t, tt.gone, waitGone.met(tt.result), "%v: unexpected gone", tt.msg)
+	}
+}
+
+// sequenceCheck returns a check function that returns each of the results in
+// turn, repeating the last result once they're exhausted.
+func sequenceCheck(results ...checkResult) func() checkResult {
+	var i int
+	return func() checkResult {
+		r := results[i]
+		if i < len(results)-1 {
+			i++
+		}
+		return r
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	unhealthy := statusResult(false, "starting")
+	healthy := statusResult(true, "")
+	failed := errResult(errors.New("failed"))
+
+	tests := []struct {
+		msg         string
+		condition   waitCondition
+		consecutive int
+		results     []checkResult
+		wantChecks  int
+		wantExit    int
+	}{
+		{
+			msg:        "healthy immediately",
+			condition:  waitHealthy,
+			results:    []checkResult{healthy},
+			wantChecks: 1,
+		},
+		{
+			msg:        "healthy after errors",
+			condition:  waitHealthy,
+			results:    []checkResult{failed, unhealthy, healthy},
+			wantChecks: 3,
+		},
+		{
+			msg:         "consecutive healthy checks",
+			condition:   waitHealthy,
+			consecutive: 2,
+			results:     []checkResult{healthy, unhealthy, healthy, healthy},
+			wantChecks:  4,
+		},
+		{
+			msg:        "unhealthy",
+			condition:  waitUnhealthy,
+			results:    []checkResult{healthy, healthy, unhealthy},
+			wantChecks: 3,
+		},
+		{
+			msg:       "never healthy",
+			condition: waitHealthy,
+			results:   []checkResult{failed},
+			wantExit:  _exitWaitTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		consecutive := tt.consecutive
+		if consecutive == 0 {
+			consecutive = 1
+		}
+
+		result, err := waitFor(sequenceCheck(tt.results...), waitOptions{
+			condition:   tt.condition,
+			maxWait:     50 * time.Millisecond,
+			consecutive: consecutive,
+			maxBackoff:  time.Millisecond,
+		})
+		if tt.wantExit != 0 {
+			assert.Equal(t, tt.wantExit, getExitCode(err), "%v: unexpected exit code", tt.msg)
+			assert.True(t, result.elapsed >= 50*time.Millisecond, "%v: returned before max wait", tt.msg)
+			continue
+		}
+
+		require.NoError(t, err, "%v: wait failed", tt.msg)
+		assert.Equal(t, tt.wantChecks, result.checks, "%v: unexpected number of checks", tt.msg)
+	}
+}
+
+func TestPrintWait(t *testing.T) {
+	var buf bytes.Buffer
+	r := waitResult{condition: waitHealthy, last: statusResult(true, ""), checks: 3, elapsed: 1500 * time.Millisecond}
+	newPrinter(&buf, outputText).printWait(r, nil)
+	assert.Equal(t, "OK peer healthy after 1.5s and 3 checks\n", buf.String())
+
+	buf.Reset()
+	err := exitError{_exitWaitTimeout, "NOT OK peer not healthy"}
+	newPrinter(&buf, outputJSON).printWait(r, err)
+	assert.Contains(t, buf.String(), `"errorClass":"wait-timeout","exitCode":14`, "Missing error class")
+	assert.Contains(t, buf.String(), `"waitFor":"healthy","checks":3,"elapsedMs":1500`, "Missing wait fields")
+}
+
+func TestIntegrationWaitFor(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	var calls int32
+	server := setupServer(t, func(ctx thrift.Context) (bool, string) {
+		return atomic.AddInt32(&calls, 1) >= 3, ""
+	})
+	defer server.Close()
+
+	// Find a port that nothing is listening on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Failed to listen")
+	ln.Close()
+
+	tests := []struct {
+		args     []string
+		wantExit int
+	}{
+		{
+			args: []string{"--peer", server.PeerInfo().HostPort, "--waitFor", "healthy", "--interval", "1ms"},
+		},
+		{
+			args: []string{"--peer", ln.Addr().String(), "--waitFor", "gone"},
+		},
+		{
+			args:     []string{"--peer", ln.Addr().String(), "--waitFor", "healthy", "--maxWait", "20ms", "--interval", "1ms"},
+			wantExit: _exitWaitTimeout,
+		},
+		{
+			args:     []string{"--peer", ln.Addr().String(), "--waitFor", "healthy", "--consecutive", "0"},
+			wantExit: _exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		exitCode = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			setArgs(append(tt.args, "--serviceName", "svc")...)
+			main()
+		}()
+
+		<-done
+		assert.Equal(t, tt.wantExit, exitCode, "Unexpected exit code for %v", tt.args)
+	}
+}
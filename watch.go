@@ -0,0 +1,251 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/uber/tcheck/health"
+)
+
+// watchState is the part of a health check result that's compared between
+// checks to detect state changes. Errors are compared by class, since their
+// messages may vary between checks, e.g. with ephemeral ports.
+type watchState struct {
+	exitCode int
+	detail   string
+}
+
+func newWatchState(r checkResult) watchState {
+	if r.err != nil {
+		code := getExitCode(r.exitErr())
+		return watchState{code, errorClass(code)}
+	}
+	state := watchState{detail: r.Message}
+	if !r.Healthy {
+		state.exitCode = _exitExplicitUnhealthy
+	}
+	return state
+}
+
+func (s watchState) healthy() bool {
+	return s.exitCode == 0
+}
+
+func (s watchState) String() string {
+	label := "OK"
+	if !s.healthy() {
+		label = "NOT OK"
+	}
+	if s.detail == "" {
+		return label
+	}
+	return fmt.Sprintf("%v %q", label, s.detail)
+}
+
+// watcher polls a health check at a fixed interval, and prints the result
+// only when the state changes.
+type watcher struct {
+	p        printer
+	check    func() checkResult
+	interval time.Duration
+	now      func() time.Time
+
+	started     time.Time
+	checks      int
+	successes   int
+	failures    int
+	transitions int
+
+	// state is the state of the last check, and streak is the number of
+	// consecutive checks that have had that state.
+	last   checkResult
+	state  watchState
+	streak int
+}
+
+func newWatcher(p printer, check func() checkResult, interval time.Duration) *watcher {
+	return &watcher{
+		p:        p,
+		check:    check,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// run health checks immediately and then every interval until stop is closed,
+// and returns the error for the last result.
+func (w *watcher) run(stop <-chan struct{}) error {
+	w.started = w.now()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.observe(w.check())
+
+		select {
+		case <-stop:
+			w.p.printWatchSummary(w)
+			return w.last.exitErr()
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe records the result of a health check, and prints it if the
+// state has changed.
+func (w *watcher) observe(r checkResult) {
+	state := newWatchState(r)
+	if state.healthy() {
+		w.successes++
+	} else {
+		w.failures++
+	}
+	w.checks++
+
+	first := w.checks == 1
+	prevState, prevStreak := w.state, w.streak
+	w.last = r
+	if !first && state == prevState {
+		w.streak++
+		return
+	}
+
+	w.state, w.streak = state, 1
+	if !first {
+		w.transitions++
+	}
+	w.p.printWatchEvent(w.now(), r, first, prevState, prevStreak)
+}
+
+func runWatch(p printer, opts checkOptions) {
+	var peer string
+	if len(peers) == 1 {
+		peer = peers[0]
+	}
+
+	c, err := newWatchChecker(peer, opts)
+	if err != nil {
+		p.printResult(checkResult{Result: health.Result{Peer: peer, ServiceName: opts.serviceName}}, err)
+		exit(err)
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	stop := make(chan struct{})
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	err = newWatcher(p, c.check, *interval).run(stop)
+	c.close()
+	exit(err)
+}
+
+// newWatchChecker validates the flags for --watch, and returns a checker for
+// the peer, or the service through Hyperbahn if peer is empty.
+func newWatchChecker(peer string, opts checkOptions) (*checker, error) {
+	if len(peers) > 1 {
+		return nil, exitError{_exitUsage, "Must specify a single peer to watch"}
+	}
+	if *interval <= 0 {
+		return nil, exitError{_exitUsage, "Must specify a positive interval"}
+	}
+	return newChecker(peer, *hostsFile, opts)
+}
+
+// jsonWatchEvent is the record written for each state change with --watch
+// and --output json.
+type jsonWatchEvent struct {
+	jsonResult
+
+	Time string `json:"time"`
+
+	// PreviousState and PreviousStreak describe the state before the change,
+	// and the number of consecutive checks that had that state.
+	PreviousState  string `json:"previousState,omitempty"`
+	PreviousStreak int    `json:"previousStreak,omitempty"`
+}
+
+// jsonWatchSummary is the record written when watching stops with
+// --output json.
+type jsonWatchSummary struct {
+	Summary     bool    `json:"summary"`
+	DurationMs  float64 `json:"durationMs"`
+	Checks      int     `json:"checks"`
+	Successes   int     `json:"successes"`
+	Failures    int     `json:"failures"`
+	Transitions int     `json:"transitions"`
+	State       string  `json:"state"`
+	Streak      int     `json:"streak"`
+}
+
+// printWatchEvent prints a health check result that changed state.
+func (p printer) printWatchEvent(t time.Time, r checkResult, first bool, prev watchState, prevStreak int) {
+	timestamp := t.Format(time.RFC3339)
+	if p.format == outputJSON {
+		event := jsonWatchEvent{
+			jsonResult: newJSONResult(r, r.exitErr()),
+			Time:       timestamp,
+		}
+		if !first {
+			event.PreviousState = prev.String()
+			event.PreviousStreak = prevStreak
+		}
+		p.writeJSON(event)
+		return
+	}
+
+	if first {
+		fmt.Fprintf(p.w, "%v %v\n", timestamp, r)
+		return
+	}
+	fmt.Fprintf(p.w, "%v %v (was %v for %v checks)\n", timestamp, r, prev, prevStreak)
+}
+
+// printWatchSummary prints a summary of the checks made by the watcher.
+func (p printer) printWatchSummary(w *watcher) {
+	duration := w.now().Sub(w.started)
+	if p.format == outputJSON {
+		p.writeJSON(jsonWatchSummary{
+			Summary:     true,
+			DurationMs:  duration.Seconds() * 1000,
+			Checks:      w.checks,
+			Successes:   w.successes,
+			Failures:    w.failures,
+			Transitions: w.transitions,
+			State:       w.state.String(),
+			Streak:      w.streak,
+		})
+		return
+	}
+
+	fmt.Fprintf(p.w, "%v checks over %v: %v OK, %v NOT OK, %v state changes, %v for the last %v checks\n",
+		w.checks, duration, w.successes, w.failures, w.transitions, w.state, w.streak)
+}
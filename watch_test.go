@@ -0,0 +1,196 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/uber/tcheck/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+func statusResult(ok bool, message string) checkResult {
+	return checkResult{Result: health.Result{Peer: "peer", ServiceName: "svc", Healthy: ok, Message: message}}
+}
+
+func errResult(err error) checkResult {
+	return checkResult{Result: health.Result{Peer: "peer", ServiceName: "svc"}, err: err}
+}
+
+// runWatcher runs a watcher over the given results, stopping after the last.
+func runWatcher(p printer, results ...checkResult) (*watcher, error) {
+	stop := make(chan struct{})
+	var i int
+	check := func() checkResult {
+		r := results[i]
+		if i++; i == len(results) {
+			close(stop)
+		}
+		return r
+	}
+
+	w := newWatcher(p, check, time.Millisecond)
+	w.now = func() time.Time { return time.Date(2016, 7, 29, 0, 0, 0, 0, time.UTC) }
+	return w, w.run(stop)
+}
+
+func TestWatcher(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := runWatcher(newPrinter(&buf, outputText),
+		statusResult(true, "up"),
+		statusResult(true, "up"),
+		statusResult(false, "draining"),
+		statusResult(false, "draining"),
+		statusResult(false, "drained"),
+		errResult(tchannel.ErrTimeout),
+		errResult(tchannel.ErrTimeout),
+		statusResult(true, "up"),
+	)
+	assert.NoError(t, err, "Watch should end with the last result")
+	assert.Equal(t, 8, w.checks, "Unexpected number of checks")
+	assert.Equal(t, 3, w.successes, "Unexpected number of successes")
+	assert.Equal(t, 5, w.failures, "Unexpected number of failures")
+	assert.Equal(t, 4, w.transitions, "Unexpected number of transitions")
+
+	const ts = "2016-07-29T00:00:00Z "
+	assert.Equal(t, strings.Join([]string{
+		ts + "OK peer",
+		ts + `NOT OK peer draining (was OK "up" for 2 checks)`,
+		ts + `NOT OK peer drained (was NOT OK "draining" for 2 checks)`,
+		ts + `NOT OK peer Error: tchannel error ErrCodeTimeout: timeout (was NOT OK "drained" for 1 checks)`,
+		ts + `OK peer (was NOT OK "timeout" for 2 checks)`,
+		`8 checks over 0s: 3 OK, 5 NOT OK, 4 state changes, OK "up" for the last 1 checks`,
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestWatcherExitCode(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := runWatcher(newPrinter(&buf, outputText),
+		statusResult(true, "up"),
+		errResult(errors.New("failed")),
+	)
+	assert.Equal(t, _exitUnknownUnhealthy, getExitCode(err), "Exit code should match the last result")
+}
+
+func TestWatcherJSON(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := runWatcher(newPrinter(&buf, outputJSON),
+		statusResult(true, "up"),
+		statusResult(false, "draining"),
+	)
+	assert.Equal(t, _exitExplicitUnhealthy, getExitCode(err), "Unexpected exit code")
+
+	dec := json.NewDecoder(&buf)
+	var first, second jsonWatchEvent
+	var summary jsonWatchSummary
+	require.NoError(t, dec.Decode(&first), "Failed to decode first event")
+	require.NoError(t, dec.Decode(&second), "Failed to decode second event")
+	require.NoError(t, dec.Decode(&summary), "Failed to decode summary")
+
+	assert.True(t, first.OK, "First event should be healthy")
+	assert.Empty(t, first.PreviousState, "First event should have no previous state")
+	assert.Equal(t, "2016-07-29T00:00:00Z", first.Time, "Unexpected time")
+
+	assert.False(t, second.OK, "Second event should be unhealthy")
+	assert.Equal(t, "draining", second.Message, "Unexpected message")
+	assert.Equal(t, `OK "up"`, second.PreviousState, "Unexpected previous state")
+	assert.Equal(t, 1, second.PreviousStreak, "Unexpected previous streak")
+
+	assert.Equal(t, jsonWatchSummary{
+		Summary:     true,
+		Checks:      2,
+		Successes:   1,
+		Failures:    1,
+		Transitions: 1,
+		State:       `NOT OK "draining"`,
+		Streak:      1,
+	}, summary)
+}
+
+func TestIntegrationWatch(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	var calls int32
+	server := setupServer(t, func(ctx thrift.Context) (bool, string) {
+		if atomic.AddInt32(&calls, 1) >= 3 {
+			return false, "draining"
+		}
+		return true, "up"
+	})
+	defer server.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		setArgs("--peer", server.PeerInfo().HostPort, "--serviceName", server.ServiceName(), "--watch", "--interval", "10ms")
+		main()
+	}()
+
+	for atomic.LoadInt32(&calls) < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+	<-done
+	assert.Equal(t, _exitExplicitUnhealthy, exitCode, "Exit code should match the last result")
+}
+
+func TestWatchUsage(t *testing.T) {
+	defer func() { _osExit = os.Exit }()
+
+	var exitCode int
+	_osExit = func(code int) {
+		exitCode = code
+		runtime.Goexit()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		setArgs("--peer", "127.0.0.1:1", "--serviceName", "svc", "--watch", "--interval", "0s")
+		main()
+	}()
+
+	<-done
+	assert.Equal(t, _exitUsage, exitCode, "Expected usage exit code")
+}